@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"campaign-management/internal/campaign"
+)
+
+// --- Analytics Handlers ---
+
+// GetAnalytics godoc
+// @Summary      Campaign Performance
+// @Description  Impressions, unique reach, clicks, CTR and dismiss rate per campaign, with totals and an hourly or daily series (gap-free, for charts).
+// @Description  Defaults to the last 24 hours by hour, or the last 30 days by day.
+// @Description  Campaigns that ran an A/B experiment also get totals per variant.
+// @Tags         Analytics
+// @Produce      json
+// @Param        campaign_id  query  string  false  "Campaign IDs, repeated or comma-separated (default all)"
+// @Param        from         query  string  false  "Range start, RFC 3339"
+// @Param        to           query  string  false  "Range end (exclusive), RFC 3339 (default now)"
+// @Param        granularity  query  string  false  "hour (default) or day"
+// @Param        tz           query  string  false  "IANA time zone for bucket boundaries, whole-hour offsets only (default UTC)"
+// @Success      200  {object}  campaign.AnalyticsReport
+// @Failure      400  {string}  string "Invalid query"
+// @Router       /admin/analytics/campaigns [get]
+func (h *Handler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := campaign.AnalyticsQuery{
+		Granularity: campaign.Granularity(params.Get("granularity")),
+		Location:    time.UTC,
+	}
+
+	for _, v := range params["campaign_id"] {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				http.Error(w, "invalid campaign_id", http.StatusBadRequest)
+				return
+			}
+			q.CampaignIDs = append(q.CampaignIDs, id)
+		}
+	}
+	if tz := params.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			http.Error(w, "invalid tz", http.StatusBadRequest)
+			return
+		}
+		q.Location = loc
+	}
+
+	var err error
+	q.To = time.Now()
+	if v := params.Get("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+	} else if q.Granularity == campaign.GranularityDay {
+		q.From = q.To.AddDate(0, 0, -30)
+	} else {
+		q.From = q.To.Add(-24 * time.Hour)
+	}
+
+	report, err := h.service.GetAnalytics(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
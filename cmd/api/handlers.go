@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"campaign-management/internal/campaign"
@@ -13,24 +17,37 @@ import (
 )
 
 type Handler struct {
-	service *campaign.Service
-	rdb     *redis.Client // Needed for seeding
+	service      *campaign.Service
+	rdb          *redis.Client        // Needed for seeding
+	syncWorker   *campaign.SyncWorker // Nil when the backend needs no Redis sync
+	eventWriter  *campaign.EventWriter
+	rollupWorker *campaign.RollupWorker
 }
 
-func NewHandler(service *campaign.Service, rdb *redis.Client) *Handler {
-	return &Handler{service: service, rdb: rdb}
+func NewHandler(service *campaign.Service, rdb *redis.Client, syncWorker *campaign.SyncWorker, eventWriter *campaign.EventWriter, rollupWorker *campaign.RollupWorker) *Handler {
+	return &Handler{service: service, rdb: rdb, syncWorker: syncWorker, eventWriter: eventWriter, rollupWorker: rollupWorker}
 }
 
 // GetPopup godoc
 // @Summary      Get Popup for User
 // @Description  Determines the best campaign popup for a user based on priority, time, and targeting.
+// @Description  Each placement (surface) is resolved independently; without one the "default" placement is used.
+// @Description  Nothing is returned while the user policy (cooldown, daily/weekly limits across campaigns) blocks the user.
+// @Description  Request context for RULES campaigns comes from the query, falling back to headers
+// @Description  (X-Platform, X-App-Version, X-Country, Accept-Language, X-Account-Tier).
 // @Tags         Client
 // @Accept       json
 // @Produce      json
-// @Param        user_id   query      int  true  "User ID"
-// @Success      200  {object}  campaign.Campaign
+// @Param        user_id      query      int     true   "User ID"
+// @Param        placement    query      string  false  "Placement, e.g. home_modal (default: default)"
+// @Param        platform     query      string  false  "Client platform (ios, android, web)"
+// @Param        app_version  query      string  false  "App version (e.g. 5.12.1)"
+// @Param        country      query      string  false  "ISO country code"
+// @Param        language     query      string  false  "Language tag (e.g. en-US)"
+// @Param        tier         query      string  false  "Account tier"
+// @Success      200  {object}  campaign.Popup
 // @Success      204  "No Content (No suitable campaign)"
-// @Failure      400  {string}  string "Invalid User ID"
+// @Failure      400  {string}  string "Invalid User ID or placement"
 // @Router       /v1/campaigns/popup [get]
 func (h *Handler) GetPopup(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.URL.Query().Get("user_id")
@@ -42,10 +59,10 @@ func (h *Handler) GetPopup(w http.ResponseWriter, r *http.Request) {
 
 	// Calculate latency
 	start := time.Now()
-	
-	p, err := h.service.GetPopup(r.Context(), userID)
+
+	p, err := h.service.GetPopup(r.Context(), userID, r.URL.Query().Get("placement"), requestContext(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -61,19 +78,95 @@ func (h *Handler) GetPopup(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(p)
 }
 
+// GetPopups godoc
+// @Summary      Get Several Popups for User
+// @Description  Returns up to "limit" eligible campaigns of a placement in priority order, e.g. for a carousel.
+// @Description  Same checks and request context as the popup endpoint; each popup has its own serve token.
+// @Description  While the user policy has a cooldown, at most one popup is returned.
+// @Tags         Client
+// @Produce      json
+// @Param        user_id      query      int     true   "User ID"
+// @Param        limit        query      int     false  "Maximum number of popups (1-20, default 5)"
+// @Param        placement    query      string  false  "Placement, e.g. home_modal (default: default)"
+// @Param        platform     query      string  false  "Client platform (ios, android, web)"
+// @Param        app_version  query      string  false  "App version (e.g. 5.12.1)"
+// @Param        country      query      string  false  "ISO country code"
+// @Param        language     query      string  false  "Language tag (e.g. en-US)"
+// @Param        tier         query      string  false  "Account tier"
+// @Success      200  {array}   campaign.Popup
+// @Failure      400  {string}  string "Invalid User ID, limit or placement"
+// @Router       /v1/campaigns/popups [get]
+func (h *Handler) GetPopups(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return
+	}
+	limit := 5
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > campaign.MaxPopups {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	start := time.Now()
+
+	popups, err := h.service.GetPopups(r.Context(), userID, r.URL.Query().Get("placement"), requestContext(r), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("X-Response-Time", time.Since(start).String())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(popups)
+}
+
+// requestContext reads the targeting attributes of a popup request; query parameters win over headers.
+func requestContext(r *http.Request) campaign.RequestContext {
+	get := func(param, header string) string {
+		if v := r.URL.Query().Get(param); v != "" {
+			return strings.TrimSpace(v)
+		}
+		return strings.TrimSpace(r.Header.Get(header))
+	}
+
+	rc := campaign.RequestContext{
+		Platform:   get("platform", "X-Platform"),
+		AppVersion: get("app_version", "X-App-Version"),
+		Country:    get("country", "X-Country"),
+		Language:   get("language", "Accept-Language"),
+		Tier:       get("tier", "X-Account-Tier"),
+	}
+	// Accept-Language: keep the preferred tag only ("en-US,en;q=0.9" -> "en-US")
+	if i := strings.IndexAny(rc.Language, ",;"); i >= 0 {
+		rc.Language = strings.TrimSpace(rc.Language[:i])
+	}
+	return rc
+}
+
 type ImpressionRequest struct {
-	UserID     int64 `json:"user_id"`
-	CampaignID int64 `json:"campaign_id"`
+	EventID    string `json:"event_id,omitempty"` // Client-generated; retries with the same ID are counted once
+	UserID     int64  `json:"user_id"`
+	CampaignID int64  `json:"campaign_id"`
+	ServeToken string `json:"serve_token,omitempty"` // From the popup response; required when tokens are enabled
+	VariantID  string `json:"variant_id,omitempty"`  // From the popup response; taken from the serve token when tokens are enabled
 }
 
 // RegisterImpression godoc
 // @Summary      Track Impression
-// @Description  Records that a user has seen a campaign.
+// @Description  Records that a user has seen a campaign. Same as an events call with action VIEW.
+// @Description  Send an event_id to make retries safe: a repeated ID is acknowledged but not counted again.
+// @Description  A serve token counts one event per action; it is acknowledged but not counted when sent again.
+// @Description  A campaign that is not live (no cached metadata) returns 404 and is not counted.
 // @Tags         Client
 // @Accept       json
 // @Produce      json
 // @Param        request body ImpressionRequest true "Impression Request"
 // @Success      200  "OK"
+// @Failure      403  {string}  string "Invalid serve token"
+// @Failure      404  {string}  string "Campaign not found"
 // @Router       /v1/campaigns/impression [post]
 func (h *Handler) RegisterImpression(w http.ResponseWriter, r *http.Request) {
 	var req ImpressionRequest
@@ -82,13 +175,79 @@ func (h *Handler) RegisterImpression(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.RegisterImpression(r.Context(), req.UserID, req.CampaignID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.RegisterImpression(r.Context(), campaign.EventInput{
+		UserID: req.UserID, CampaignID: req.CampaignID, EventID: req.EventID, ServeToken: req.ServeToken, VariantID: req.VariantID,
+	}); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type EventRequest struct {
+	EventID    string               `json:"event_id,omitempty"` // Client-generated; retries with the same ID are counted once
+	UserID     int64                `json:"user_id"`
+	CampaignID int64                `json:"campaign_id"`
+	Action     campaign.EventAction `json:"action"`                // VIEW, CLICK or DISMISS
+	ServeToken string               `json:"serve_token,omitempty"` // From the popup response; required when tokens are enabled
+	VariantID  string               `json:"variant_id,omitempty"`  // From the popup response; taken from the serve token when tokens are enabled
+}
+
+// RecordEvent godoc
+// @Summary      Track Event
+// @Description  Records a VIEW, CLICK or DISMISS in the event log. Views count toward the frequency cap,
+// @Description  clicks toward the click cap, and a dismiss hides the campaign when its dismiss_hides is set.
+// @Description  Send an event_id to make retries safe: a repeated ID is acknowledged but not counted again.
+// @Description  A serve token counts one event per action; it is acknowledged but not counted when sent again.
+// @Description  For A/B campaigns the variant the popup showed is recorded with the event: the one signed into the serve token,
+// @Description  or the echoed variant_id when tokens are disabled.
+// @Tags         Client
+// @Accept       json
+// @Produce      json
+// @Param        request body EventRequest true "Event Request"
+// @Success      200  "OK"
+// @Failure      400  {string}  string "Invalid action"
+// @Failure      403  {string}  string "Invalid serve token"
+// @Failure      404  {string}  string "Campaign not found"
+// @Router       /v1/campaigns/events [post]
+func (h *Handler) RecordEvent(w http.ResponseWriter, r *http.Request) {
+	var req EventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.RecordEvent(r.Context(), campaign.EventInput{
+		UserID: req.UserID, CampaignID: req.CampaignID, Action: req.Action, EventID: req.EventID, ServeToken: req.ServeToken, VariantID: req.VariantID,
+	}); err != nil {
+		writeError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
+// writeError maps service errors to HTTP status codes.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, campaign.ErrNotFound), errors.Is(err, campaign.ErrSegmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, campaign.ErrInvalidServeToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, campaign.ErrSegmentExists), errors.Is(err, campaign.ErrSegmentInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, campaign.ErrNotSegment), errors.Is(err, campaign.ErrInvalidUserID),
+		errors.Is(err, campaign.ErrInvalidSegmentName), errors.Is(err, campaign.ErrUsesNamedSegment),
+		errors.Is(err, campaign.ErrInvalidRules), errors.Is(err, campaign.ErrInvalidAction), errors.Is(err, campaign.ErrInvalidEventID),
+		errors.Is(err, campaign.ErrInvalidAnalyticsQuery), errors.Is(err, campaign.ErrInvalidPlacement),
+		errors.Is(err, campaign.ErrInvalidWeight), errors.Is(err, campaign.ErrInvalidVariants),
+		errors.Is(err, campaign.ErrInvalidBudget), errors.Is(err, campaign.ErrInvalidPolicy),
+		errors.Is(err, campaign.ErrInvalidSchedule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // POST /debug/seed
 // Helper to inject data into Redis for testing
 func (h *Handler) SeedData(w http.ResponseWriter, r *http.Request) {
@@ -115,12 +274,12 @@ func (h *Handler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	
+
 	if err := h.service.CreateCampaign(r.Context(), &c); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(c)
 }
@@ -140,23 +299,23 @@ func (h *Handler) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	
+
 	if c.ID == 0 {
 		http.Error(w, "id is required", http.StatusBadRequest)
 		return
 	}
 
 	if err := h.service.UpdateCampaign(r.Context(), &c); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	
+
 	json.NewEncoder(w).Encode(c)
 }
 
 // DeleteCampaign godoc
 // @Summary      Delete Campaign
-// @Description  Deletes a campaign from DB and Redis.
+// @Description  Deletes a campaign from DB and Redis, with its events and analytics.
 // @Tags         Admin
 // @Param        id   query      int  true  "Campaign ID"
 // @Success      204  "No Content"
@@ -176,6 +335,50 @@ func (h *Handler) DeleteCampaign(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ExplainPopup godoc
+// @Summary      Explain Popup Decision
+// @Description  Runs the GetPopup evaluation for a user and lists every active campaign of the placement, in priority order, with the reason it was rejected
+// @Description  (missing_metadata, not_started, ended, off_schedule, not_targeted, rules_not_matched, frequency_cap_reached, click_cap_reached, dismissed, budget_exhausted, ahead_of_pace, outranked) and which one won.
+// @Description  When the user policy blocks the user (user_cooldown, user_daily_limit, user_weekly_limit), "blocked" is set and every otherwise eligible campaign carries that reason.
+// @Description  Nothing is recorded. Takes the same request context as the popup endpoint.
+// @Description  Pass "at" to simulate the decision at another instant (e.g. before a campaign starts).
+// @Tags         Admin
+// @Produce      json
+// @Param        user_id      query      int     true   "User ID"
+// @Param        placement    query      string  false  "Placement, e.g. home_modal (default: default)"
+// @Param        platform     query      string  false  "Client platform (ios, android, web)"
+// @Param        app_version  query      string  false  "App version (e.g. 5.12.1)"
+// @Param        country      query      string  false  "ISO country code"
+// @Param        language     query      string  false  "Language tag (e.g. en-US)"
+// @Param        tier         query      string  false  "Account tier"
+// @Param        at           query      string  false  "Evaluate at this instant, RFC 3339 (default now)"
+// @Success      200  {object}  campaign.Decision
+// @Failure      400  {string}  string "Invalid User ID"
+// @Router       /admin/popup/explain [get]
+func (h *Handler) ExplainPopup(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	var at time.Time
+	if v := r.URL.Query().Get("at"); v != "" {
+		if at, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid at", http.StatusBadRequest)
+			return
+		}
+	}
+
+	d, err := h.service.ExplainPopup(r.Context(), userID, r.URL.Query().Get("placement"), requestContext(r), at)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
 // ListCampaigns godoc
 // @Summary      List All Campaigns
 // @Description  Fetches all campaigns from PostgreSQL.
@@ -220,17 +423,272 @@ func (h *Handler) GetCampaign(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(c)
 }
 
+// --- Campaign Whitelist Handlers ---
+
+type TargetsRequest struct {
+	UserIDs []int64 `json:"user_ids"`
+}
+
+type TargetsResponse struct {
+	CampaignID int64   `json:"campaign_id,omitempty"`
+	Segment    string  `json:"segment,omitempty"`
+	UserIDs    []int64 `json:"user_ids"`
+	NextAfter  int64   `json:"next_after,omitempty"` // Pass as "after" to get the next page
+}
+
+// ListTargets godoc
+// @Summary      List Segment Targets
+// @Description  Lists targeted user IDs of a SEGMENT campaign from PostgreSQL, ascending, paginated by user ID.
+// @Tags         Admin
+// @Produce      json
+// @Param        id      query  int  true   "Campaign ID"
+// @Param        after   query  int  false  "Return user IDs greater than this"
+// @Param        limit   query  int  false  "Page size (default 1000, max 10000)"
+// @Success      200  {object}  TargetsResponse
+// @Router       /admin/campaigns/targets [get]
+func (h *Handler) ListTargets(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	after, limit, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	userIDs, err := h.service.ListTargets(r.Context(), id, after, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(newTargetsPage(TargetsResponse{CampaignID: id}, userIDs, limit))
+}
+
+// AddTargets godoc
+// @Summary      Add Segment Targets
+// @Description  Whitelists users for a SEGMENT campaign in DB and Redis bitmap.
+// @Tags         Admin
+// @Accept       json
+// @Param        id       query  int             true  "Campaign ID"
+// @Param        request  body   TargetsRequest  true  "User IDs"
+// @Success      204  "No Content"
+// @Router       /admin/campaigns/targets [post]
+func (h *Handler) AddTargets(w http.ResponseWriter, r *http.Request) {
+	h.updateTargets(w, r, h.service.AddTargets)
+}
+
+// ReplaceTargets godoc
+// @Summary      Replace Segment Targets
+// @Description  Replaces the whole target list of a SEGMENT campaign in DB and Redis bitmap.
+// @Tags         Admin
+// @Accept       json
+// @Param        id       query  int             true  "Campaign ID"
+// @Param        request  body   TargetsRequest  true  "User IDs"
+// @Success      204  "No Content"
+// @Router       /admin/campaigns/targets [put]
+func (h *Handler) ReplaceTargets(w http.ResponseWriter, r *http.Request) {
+	h.updateTargets(w, r, h.service.ReplaceTargets)
+}
+
+// RemoveTargets godoc
+// @Summary      Remove Segment Targets
+// @Description  Removes users from a SEGMENT campaign in DB and Redis bitmap.
+// @Tags         Admin
+// @Accept       json
+// @Param        id       query  int             true  "Campaign ID"
+// @Param        request  body   TargetsRequest  true  "User IDs"
+// @Success      204  "No Content"
+// @Router       /admin/campaigns/targets [delete]
+func (h *Handler) RemoveTargets(w http.ResponseWriter, r *http.Request) {
+	h.updateTargets(w, r, h.service.RemoveTargets)
+}
+
+func (h *Handler) updateTargets(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, campaignID int64, userIDs []int64) error) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req TargetsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := apply(r.Context(), id, req.UserIDs); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UploadTargets godoc
+// @Summary      Upload Segment Targets (CSV)
+// @Description  Streams a CSV of user_id (first column, optional "user_id" header) into a SEGMENT campaign, in batches to DB and Redis bitmap. Invalid lines are skipped and reported.
+// @Tags         Admin
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id    query     int     true   "Campaign ID"
+// @Param        mode  query     string  false  "replace (default) or append"
+// @Param        file  formData  file    true   "CSV file"
+// @Success      200  {object}  campaign.ImportReport
+// @Failure      400  {object}  campaign.ImportReport "No valid rows"
+// @Router       /admin/campaigns/targets/upload [post]
+func (h *Handler) UploadTargets(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	streamImport(w, r, func(ctx context.Context, csv io.Reader, replace bool) (*campaign.ImportReport, error) {
+		return h.service.ImportTargets(ctx, id, csv, replace)
+	})
+}
+
+// parsePage reads the "after" / "limit" keyset pagination parameters, writing a 400 on error.
+func parsePage(w http.ResponseWriter, r *http.Request) (after int64, limit int, ok bool) {
+	var err error
+	after = -1
+	if v := r.URL.Query().Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "invalid after", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+	limit = 1000
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > 10000 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+	return after, limit, true
+}
+
+func newTargetsPage(resp TargetsResponse, userIDs []int64, limit int) TargetsResponse {
+	resp.UserIDs = userIDs
+	if len(userIDs) == limit {
+		resp.NextAfter = userIDs[len(userIDs)-1]
+	}
+	return resp
+}
+
+// streamImport feeds the "file" part of a multipart upload to run, honouring ?mode=replace|append.
+func streamImport(w http.ResponseWriter, r *http.Request, run func(ctx context.Context, csv io.Reader, replace bool) (*campaign.ImportReport, error)) {
+	var replace bool
+	switch r.URL.Query().Get("mode") {
+	case "", "replace":
+		replace = true
+	case "append":
+		replace = false
+	default:
+		http.Error(w, "mode must be replace or append", http.StatusBadRequest)
+		return
+	}
+
+	// Stream the multipart body instead of ParseMultipartForm, files can be millions of rows
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			http.Error(w, "missing file part", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		report, err := run(r.Context(), part, replace)
+		part.Close()
+		if errors.Is(err, campaign.ErrEmptyImport) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+}
+
 // SyncData godoc
 // @Summary      Sync DB to Redis
-// @Description  Manually triggers synchronization of all campaigns from DB to Redis.
+// @Description  Reconciles Redis with the DB (adds missing, refreshes stale, removes orphaned campaigns, restores missing target bitmaps) and returns the diff.
 // @Tags         Debug
-// @Success      200  "Synced"
+// @Produce      json
+// @Param        dry_run   query      bool  false  "Only report the diff, do not apply it"
+// @Success      200  {object}  campaign.SyncReport
 // @Router       /debug/sync [post]
 func (h *Handler) SyncData(w http.ResponseWriter, r *http.Request) {
-	if err := h.service.SyncCampaigns(r.Context()); err != nil {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	report, err := h.service.Reconcile(r.Context(), dryRun)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Synced DB to Redis"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// SyncStatus godoc
+// @Summary      Sync Worker Status
+// @Description  Reports lag and failures of the background DB to Redis sync.
+// @Tags         Debug
+// @Produce      json
+// @Success      200  {object}  campaign.SyncStatus
+// @Failure      404  {string}  string "Sync worker not running"
+// @Router       /debug/sync/status [get]
+func (h *Handler) SyncStatus(w http.ResponseWriter, r *http.Request) {
+	if h.syncWorker == nil {
+		http.Error(w, "sync worker not running for this backend", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.syncWorker.Status())
+}
+
+// EventsStatus godoc
+// @Summary      Event Writer Status
+// @Description  Reports queue depth, written/dropped counts and failures of the asynchronous event log writer.
+// @Tags         Debug
+// @Produce      json
+// @Success      200  {object}  campaign.EventWriterStatus
+// @Failure      404  {string}  string "Event writer not running"
+// @Router       /debug/events/status [get]
+func (h *Handler) EventsStatus(w http.ResponseWriter, r *http.Request) {
+	if h.eventWriter == nil {
+		http.Error(w, "event writer not running for this backend", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.eventWriter.Status())
+}
+
+// RollupStatus godoc
+// @Summary      Rollup Worker Status
+// @Description  Reports the watermark (hours before it are served from rollups), lag and failures of the hourly event rollup.
+// @Tags         Debug
+// @Produce      json
+// @Success      200  {object}  campaign.RollupStatus
+// @Router       /debug/rollup/status [get]
+func (h *Handler) RollupStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.rollupWorker.Status())
 }
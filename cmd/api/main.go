@@ -3,10 +3,17 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"campaign-management/internal/campaign"
+	campaignMemory "campaign-management/internal/platform/memory"
 	campaignPostgres "campaign-management/internal/platform/postgres"
 	campaignRedis "campaign-management/internal/platform/redis"
 
@@ -23,20 +30,23 @@ import (
 // @host            localhost:8080
 // @BasePath        /
 func main() {
-	// 1. Init Redis Connection (Infra)
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	})
-	
-	if err := rdb.Ping(context.Background()).Err(); err != nil {
-		log.Fatalf("Could not initialize Redis: %v", err)
-	}
-	defer rdb.Close()
-	log.Println("✅ Redis Connected")
+	// Hot-path backend: "redis" (default), "memory" (Postgres + RAM) or "postgres" (Postgres only)
+	repoBackend := flag.String("repo", "redis", "campaign repository backend: redis | memory | postgres")
+	refreshInterval := flag.Duration("refresh-interval", 30*time.Second, "reload interval for the memory backend")
+	syncInterval := flag.Duration("sync-interval", time.Minute, "DB to Redis sync interval for the redis backend")
+	eventBuffer := flag.Int("event-buffer", 10000, "events queued in memory before new ones are dropped")
+	eventBatch := flag.Int("event-batch", 500, "events per INSERT into campaign_impressions")
+	eventFlushInterval := flag.Duration("event-flush-interval", time.Second, "max time an event waits before being written")
+	rotation := flag.String("rotation", string(campaign.RotationOff), "order of campaigns sharing a priority: off | weighted | sticky (weighted, fixed per user)")
+	rollupInterval := flag.Duration("rollup-interval", time.Minute, "how often events are aggregated into hourly rollups")
+	serveTokenKeysFile := flag.String("serve-token-keys-file", "", "file with serve token keys \"kid:secret,...\" (reloaded on SIGHUP); overrides $SERVE_TOKEN_KEYS")
+	serveTokenTTL := flag.Duration("serve-token-ttl", 24*time.Hour, "how long after a popup is served its events are accepted")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	// 2. Init SQL Connection (Infra)
+	// 1. Init SQL Connection (Infra)
 	// User: user (local), DB: campaign_db, SSL: disable
 	connStr := "user=user dbname=campaign_db sslmode=disable"
 	db, err := sql.Open("postgres", connStr)
@@ -50,18 +60,128 @@ func main() {
 	}
 	log.Println("✅ PostgreSQL Connected (campaign_db)")
 
-	// 3. Init Layers
-	repo := campaignRedis.NewRepository(rdb)
+	// 2. Init Hot-Path Repository (Infra)
 	store := campaignPostgres.NewStore(db)
+
+	var repo campaign.Repository
+	var rdb *redis.Client
+	var syncWorker *campaign.SyncWorker
+	switch *repoBackend {
+	case "redis":
+		rdb = redis.NewClient(&redis.Options{
+			Addr:     "localhost:6379",
+			Password: "",
+			DB:       0,
+		})
+
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			log.Fatalf("Could not initialize Redis: %v", err)
+		}
+		defer rdb.Close()
+		log.Println("✅ Redis Connected")
+
+		repo = campaignRedis.NewRepository(rdb)
+	case "memory":
+		memRepo := campaignMemory.NewRepository(store, *refreshInterval)
+		if err := memRepo.Refresh(ctx); err != nil {
+			log.Fatalf("Could not warm up in-memory campaigns: %v", err)
+		}
+		go memRepo.Run(ctx)
+		log.Printf("✅ In-Memory Repository Loaded (refresh every %s)", *refreshInterval)
+
+		repo = memRepo
+	case "postgres":
+		log.Println("✅ PostgreSQL Repository (no cache)")
+		repo = campaignPostgres.NewRepository(db)
+	default:
+		log.Fatalf("Unknown repository backend %q", *repoBackend)
+	}
+
+	// 3. Init Layers
 	svc := campaign.NewService(repo, store)
-	handler := NewHandler(svc, rdb)
+
+	if mode := campaign.Rotation(*rotation); mode.Valid() {
+		svc.SetRotation(mode)
+	} else {
+		log.Fatalf("Unknown rotation %q", *rotation)
+	}
+
+	// Serve tokens: events must echo a token signed when the popup was served.
+	// The first key signs, the others still verify; rotate by prepending a new key and reloading.
+	if loadKeys := serveTokenKeys(*serveTokenKeysFile); loadKeys != nil {
+		if *serveTokenTTL > campaign.EventDedupeWindow {
+			// A token records one event per action only while its claim is remembered
+			log.Fatalf("-serve-token-ttl must not exceed %s", campaign.EventDedupeWindow)
+		}
+		tokens := campaign.NewServeTokens(*serveTokenTTL)
+		if err := loadKeys(tokens); err != nil {
+			log.Fatalf("Could not load serve token keys: %v", err)
+		}
+		svc.SetServeTokens(tokens)
+		log.Printf("✅ Serve Tokens Required (ttl %s)", *serveTokenTTL)
+
+		if *serveTokenKeysFile != "" {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			go func() {
+				for range hup {
+					if err := loadKeys(tokens); err != nil {
+						log.Printf("serve token key reload failed, keeping the old keys: %v", err)
+						continue
+					}
+					log.Println("Serve token keys reloaded")
+				}
+			}()
+		}
+	} else {
+		log.Println("⚠️  Serve tokens disabled: events are accepted without proof the popup was served")
+	}
+
+	// Redis is a cache of the DB, keep it reconciled in the background
+	if rdb != nil {
+		syncWorker = campaign.NewSyncWorker(svc, *syncInterval)
+		go syncWorker.Run(ctx)
+		log.Printf("✅ Sync Worker Started (every %s)", *syncInterval)
+	}
+
+	// Events go to Postgres in batches, off the request path, when Redis or memory holds the
+	// counters. The postgres backend counts the event log itself, so a lagging or dropped event
+	// would let users past their caps: it keeps the default synchronous write.
+	// The writer gets its own context so it can flush after the server has stopped accepting requests.
+	var eventWriter *campaign.EventWriter
+	writerCtx, stopWriter := context.WithCancel(context.Background())
+	writerDone := make(chan struct{})
+	if *repoBackend != "postgres" {
+		eventWriter = campaign.NewEventWriter(store, *eventBuffer, *eventBatch, *eventFlushInterval)
+		svc.SetEventSink(eventWriter)
+		go func() {
+			eventWriter.Run(writerCtx)
+			close(writerDone)
+		}()
+		log.Printf("✅ Event Writer Started (batch %d, flush every %s)", *eventBatch, *eventFlushInterval)
+	} else {
+		close(writerDone)
+		log.Println("✅ Events Written Synchronously (caps are counted from the event log)")
+	}
+
+	// Analytics read hourly rollups plus the raw tail, keep the rollups moving
+	rollupWorker := campaign.NewRollupWorker(store, *rollupInterval)
+	go rollupWorker.Run(ctx)
+	log.Printf("✅ Rollup Worker Started (every %s)", *rollupInterval)
+
+	handler := NewHandler(svc, rdb, syncWorker, eventWriter, rollupWorker)
 
 	// 4. Routes
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /debug/seed", handler.SeedData)
 	mux.HandleFunc("GET /v1/campaigns/popup", handler.GetPopup)
+	mux.HandleFunc("GET /v1/campaigns/popups", handler.GetPopups)
 	mux.HandleFunc("POST /v1/campaigns/impression", handler.RegisterImpression)
+	mux.HandleFunc("POST /v1/campaigns/events", handler.RecordEvent)
 	mux.HandleFunc("POST /debug/sync", handler.SyncData)
+	mux.HandleFunc("GET /debug/sync/status", handler.SyncStatus)
+	mux.HandleFunc("GET /debug/events/status", handler.EventsStatus)
+	mux.HandleFunc("GET /debug/rollup/status", handler.RollupStatus)
 
 	// Admin
 	mux.HandleFunc("POST /admin/campaigns", handler.CreateCampaign)
@@ -69,6 +189,29 @@ func main() {
 	mux.HandleFunc("DELETE /admin/campaigns", handler.DeleteCampaign)
 	mux.HandleFunc("GET /admin/campaigns", handler.ListCampaigns)
 	mux.HandleFunc("GET /admin/campaigns/detail", handler.GetCampaign)
+	mux.HandleFunc("GET /admin/popup/explain", handler.ExplainPopup)
+	mux.HandleFunc("GET /admin/campaigns/targets", handler.ListTargets)
+	mux.HandleFunc("POST /admin/campaigns/targets", handler.AddTargets)
+	mux.HandleFunc("PUT /admin/campaigns/targets", handler.ReplaceTargets)
+	mux.HandleFunc("DELETE /admin/campaigns/targets", handler.RemoveTargets)
+	mux.HandleFunc("POST /admin/campaigns/targets/upload", handler.UploadTargets)
+
+	// Admin: Named Segments
+	mux.HandleFunc("GET /admin/segments", handler.ListSegments)
+	mux.HandleFunc("POST /admin/segments", handler.CreateSegment)
+	mux.HandleFunc("DELETE /admin/segments", handler.DeleteSegment)
+	mux.HandleFunc("GET /admin/segments/members", handler.ListSegmentMembers)
+	mux.HandleFunc("POST /admin/segments/members", handler.AddSegmentMembers)
+	mux.HandleFunc("PUT /admin/segments/members", handler.ReplaceSegmentMembers)
+	mux.HandleFunc("DELETE /admin/segments/members", handler.RemoveSegmentMembers)
+	mux.HandleFunc("POST /admin/segments/members/upload", handler.UploadSegmentMembers)
+
+	// Admin: User Policy
+	mux.HandleFunc("GET /admin/policy", handler.GetUserPolicy)
+	mux.HandleFunc("PUT /admin/policy", handler.SetUserPolicy)
+
+	// Admin: Analytics
+	mux.HandleFunc("GET /admin/analytics/campaigns", handler.GetAnalytics)
 
 	// Swagger
 	mux.HandleFunc("GET /swagger/", httpSwagger.Handler(
@@ -76,8 +219,39 @@ func main() {
 	))
 
 	// 5. Start Server
-	log.Println("🚀 Campaign Service listening on :8080")
-	if err := http.ListenAndServe(":8080", mux); err != nil {
-		log.Fatal(err)
+	server := &http.Server{Addr: ":8080", Handler: mux}
+	go func() {
+		log.Println("🚀 Campaign Service listening on :8080")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// 6. Graceful Shutdown: stop taking requests, then flush the event log
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+	stopWriter()
+	<-writerDone
+}
+
+// serveTokenKeys returns a loader for the configured key source, or nil when none is configured.
+func serveTokenKeys(file string) func(*campaign.ServeTokens) error {
+	if file != "" {
+		return func(t *campaign.ServeTokens) error {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			return t.SetKeys(strings.TrimSpace(string(b)))
+		}
+	}
+	if spec := os.Getenv("SERVE_TOKEN_KEYS"); spec != "" {
+		return func(t *campaign.ServeTokens) error { return t.SetKeys(spec) }
 	}
+	return nil
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"campaign-management/internal/campaign"
+)
+
+// --- User Policy Handlers ---
+
+// GetUserPolicy godoc
+// @Summary      Get User Policy
+// @Description  Returns the policy limiting how often one user sees popups across all campaigns. Empty when none was ever set.
+// @Tags         Policy
+// @Produce      json
+// @Success      200  {object}  campaign.UserPolicy
+// @Router       /admin/policy [get]
+func (h *Handler) GetUserPolicy(w http.ResponseWriter, r *http.Request) {
+	p, err := h.service.GetUserPolicy(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if p == nil {
+		p = &campaign.UserPolicy{}
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// SetUserPolicy godoc
+// @Summary      Set User Policy
+// @Description  Replaces the user policy in DB and Redis: a cooldown after any popup was seen and daily/weekly limits across all campaigns,
+// @Description  on top of each campaign's own caps. Zero disables a limit, so {} turns the policy off.
+// @Tags         Policy
+// @Accept       json
+// @Produce      json
+// @Param        policy body campaign.UserPolicy true "Policy (cooldown_seconds, per_day, per_week)"
+// @Success      200  {object}  campaign.UserPolicy
+// @Failure      400  {string}  string "Invalid policy"
+// @Router       /admin/policy [put]
+func (h *Handler) SetUserPolicy(w http.ResponseWriter, r *http.Request) {
+	var p campaign.UserPolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.SetUserPolicy(r.Context(), &p); err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"campaign-management/internal/campaign"
+)
+
+// --- Named Segment Handlers ---
+
+// ListSegments godoc
+// @Summary      List Segments
+// @Description  Lists named, reusable segments from PostgreSQL.
+// @Tags         Segments
+// @Produce      json
+// @Success      200  {array}  campaign.Segment
+// @Router       /admin/segments [get]
+func (h *Handler) ListSegments(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.ListSegments(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateSegment godoc
+// @Summary      Create Segment
+// @Description  Creates a named segment campaigns can reference via target_segment.
+// @Tags         Segments
+// @Accept       json
+// @Produce      json
+// @Param        segment body campaign.Segment true "Segment (name, description)"
+// @Success      201  {object}  campaign.Segment
+// @Failure      409  {string}  string "Segment already exists"
+// @Router       /admin/segments [post]
+func (h *Handler) CreateSegment(w http.ResponseWriter, r *http.Request) {
+	var seg campaign.Segment
+	if err := json.NewDecoder(r.Body).Decode(&seg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateSegment(r.Context(), &seg); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(seg)
+}
+
+// DeleteSegment godoc
+// @Summary      Delete Segment
+// @Description  Deletes a segment and its members from DB and Redis. Fails while campaigns still reference it.
+// @Tags         Segments
+// @Param        name  query  string  true  "Segment name"
+// @Success      204  "No Content"
+// @Failure      409  {string}  string "Segment is referenced by campaigns"
+// @Router       /admin/segments [delete]
+func (h *Handler) DeleteSegment(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteSegment(r.Context(), r.URL.Query().Get("name")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSegmentMembers godoc
+// @Summary      List Segment Members
+// @Description  Lists user IDs of a named segment from PostgreSQL, ascending, paginated by user ID.
+// @Tags         Segments
+// @Produce      json
+// @Param        name    query  string  true   "Segment name"
+// @Param        after   query  int     false  "Return user IDs greater than this"
+// @Param        limit   query  int     false  "Page size (default 1000, max 10000)"
+// @Success      200  {object}  TargetsResponse
+// @Router       /admin/segments/members [get]
+func (h *Handler) ListSegmentMembers(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	after, limit, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	userIDs, err := h.service.ListSegmentMembers(r.Context(), name, after, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(newTargetsPage(TargetsResponse{Segment: name}, userIDs, limit))
+}
+
+// AddSegmentMembers godoc
+// @Summary      Add Segment Members
+// @Description  Adds users to a named segment in DB and Redis bitmap.
+// @Tags         Segments
+// @Accept       json
+// @Param        name     query  string          true  "Segment name"
+// @Param        request  body   TargetsRequest  true  "User IDs"
+// @Success      204  "No Content"
+// @Router       /admin/segments/members [post]
+func (h *Handler) AddSegmentMembers(w http.ResponseWriter, r *http.Request) {
+	h.updateSegmentMembers(w, r, h.service.AddSegmentMembers)
+}
+
+// ReplaceSegmentMembers godoc
+// @Summary      Replace Segment Members
+// @Description  Replaces all members of a named segment in DB and Redis bitmap.
+// @Tags         Segments
+// @Accept       json
+// @Param        name     query  string          true  "Segment name"
+// @Param        request  body   TargetsRequest  true  "User IDs"
+// @Success      204  "No Content"
+// @Router       /admin/segments/members [put]
+func (h *Handler) ReplaceSegmentMembers(w http.ResponseWriter, r *http.Request) {
+	h.updateSegmentMembers(w, r, h.service.ReplaceSegmentMembers)
+}
+
+// RemoveSegmentMembers godoc
+// @Summary      Remove Segment Members
+// @Description  Removes users from a named segment in DB and Redis bitmap.
+// @Tags         Segments
+// @Accept       json
+// @Param        name     query  string          true  "Segment name"
+// @Param        request  body   TargetsRequest  true  "User IDs"
+// @Success      204  "No Content"
+// @Router       /admin/segments/members [delete]
+func (h *Handler) RemoveSegmentMembers(w http.ResponseWriter, r *http.Request) {
+	h.updateSegmentMembers(w, r, h.service.RemoveSegmentMembers)
+}
+
+func (h *Handler) updateSegmentMembers(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, name string, userIDs []int64) error) {
+	var req TargetsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := apply(r.Context(), r.URL.Query().Get("name"), req.UserIDs); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UploadSegmentMembers godoc
+// @Summary      Upload Segment Members (CSV)
+// @Description  Streams a CSV of user_id (first column, optional "user_id" header) into a named segment, in batches to DB and Redis bitmap. Invalid lines are skipped and reported.
+// @Tags         Segments
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        name  query     string  true   "Segment name"
+// @Param        mode  query     string  false  "replace (default) or append"
+// @Param        file  formData  file    true   "CSV file"
+// @Success      200  {object}  campaign.ImportReport
+// @Failure      400  {object}  campaign.ImportReport "No valid rows"
+// @Router       /admin/segments/members/upload [post]
+func (h *Handler) UploadSegmentMembers(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	streamImport(w, r, func(ctx context.Context, csv io.Reader, replace bool) (*campaign.ImportReport, error) {
+		return h.service.ImportSegmentMembers(ctx, name, csv, replace)
+	})
+}
@@ -5,10 +5,24 @@ CREATE TABLE IF NOT EXISTS campaigns (
     image_url TEXT,
     action_url TEXT,
     priority INT DEFAULT 0,
+    weight INT DEFAULT 0,        -- Share within the priority tier under rotation (0 = 1)
     start_time TIMESTAMP WITH TIME ZONE,
     end_time TIMESTAMP WITH TIME ZONE,
-    max_frequency INT DEFAULT 1,
-    target_type VARCHAR(20) DEFAULT 'ALL', -- 'ALL', 'SEGMENT'
+    max_frequency INT DEFAULT 1, -- Lifetime cap per user (0 = unlimited)
+    cap_per_day INT DEFAULT 0,   -- Per calendar day (0 = unlimited)
+    cap_per_week INT DEFAULT 0,  -- Per ISO week (0 = unlimited)
+    click_cap_per_day INT DEFAULT 0,  -- Clicks after which the campaign stops showing (0 = unlimited)
+    click_cap_per_week INT DEFAULT 0,
+    click_cap_lifetime INT DEFAULT 0,
+    dismiss_hides BOOLEAN DEFAULT false, -- A DISMISS hides the campaign from that user
+    budget BIGINT DEFAULT 0,             -- Total impressions across all users (0 = unlimited)
+    pacing VARCHAR(10),                  -- 'asap' (NULL) or 'even' over start_time..end_time
+    target_type VARCHAR(20) DEFAULT 'ALL', -- 'ALL', 'SEGMENT', 'RULES'
+    target_segment VARCHAR(64),            -- Named segment (NULL = own whitelist in campaign_targets)
+    target_rules JSONB,                    -- Request-context conditions of a RULES campaign
+    variants JSONB,                        -- A/B creatives with their traffic weights
+    schedule JSONB,                        -- Recurring weekdays/month days/hours within start_time..end_time (NULL = always)
+    placement VARCHAR(32) NOT NULL DEFAULT 'default', -- Surface the campaign competes on (home modal, bottom sheet, ...)
     is_active BOOLEAN DEFAULT true,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
@@ -21,14 +35,142 @@ CREATE TABLE IF NOT EXISTS campaign_targets (
     PRIMARY KEY (campaign_id, user_id)
 );
 
+-- Named Segments (reusable audiences, referenced by campaigns.target_segment)
+CREATE TABLE IF NOT EXISTS segments (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS segment_members (
+    segment_name VARCHAR(64) REFERENCES segments(name) ON DELETE CASCADE,
+    user_id BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (segment_name, user_id)
+);
+
+-- User Policy (limits across all campaigns, a single row)
+CREATE TABLE IF NOT EXISTS user_policy (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    cooldown_seconds INT NOT NULL DEFAULT 0, -- Minimum gap between two popups (0 = none)
+    per_day INT NOT NULL DEFAULT 0,          -- Popups per user per calendar day (0 = unlimited)
+    per_week INT NOT NULL DEFAULT 0,         -- Popups per user per ISO week (0 = unlimited)
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 -- Campaign Impressions (Analytics)
 CREATE TABLE IF NOT EXISTS campaign_impressions (
     id BIGSERIAL PRIMARY KEY,
-    campaign_id BIGINT REFERENCES campaigns(id),
+    event_id VARCHAR(128), -- Client-generated ID, unique per user when set (retries are dropped)
+    campaign_id BIGINT REFERENCES campaigns(id) ON DELETE CASCADE, -- Events go with their campaign
+    variant_id VARCHAR(32) NOT NULL DEFAULT '', -- A/B variant the user was assigned ('' = no experiment)
     user_id BIGINT,
     action VARCHAR(50), -- 'VIEW', 'CLICK', 'DISMISS'
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(), -- When the event happened
+    inserted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() -- When it reached the DB (late events are rolled up again)
+);
+
+-- Hourly Rollups of campaign_impressions (UTC hours, rebuilt whole on late events)
+CREATE TABLE IF NOT EXISTS campaign_metrics_hourly (
+    campaign_id BIGINT,
+    variant_id VARCHAR(32) NOT NULL DEFAULT '',
+    hour TIMESTAMP WITH TIME ZONE,
+    impressions BIGINT NOT NULL DEFAULT 0,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    dismisses BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (campaign_id, variant_id, hour)
+);
+
+-- Distinct viewers per hour: reach is not additive, so day/range reach is counted from here
+CREATE TABLE IF NOT EXISTS campaign_viewers_hourly (
+    campaign_id BIGINT,
+    variant_id VARCHAR(32) NOT NULL DEFAULT '',
+    hour TIMESTAMP WITH TIME ZONE,
+    user_id BIGINT,
+    PRIMARY KEY (campaign_id, variant_id, hour, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS rollup_state (
+    name VARCHAR(64) PRIMARY KEY,
+    watermark TIMESTAMP WITH TIME ZONE,     -- Hours before this are served from the rollups
+    ingested_until TIMESTAMP WITH TIME ZONE -- Raw rows inserted up to here have been rolled up
+);
+
+-- Event claims of the Postgres hot path (Option 1): a serve token records one event per action
+CREATE TABLE IF NOT EXISTS event_claims (
+    user_id BIGINT,
+    claim VARCHAR(200),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, claim)
 );
 
 -- Index for analytics speed
 CREATE INDEX IF NOT EXISTS idx_impressions_campaign_user ON campaign_impressions(campaign_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_impressions_user_action ON campaign_impressions(user_id, campaign_id, action, created_at);
+CREATE INDEX IF NOT EXISTS idx_impressions_campaign_time ON campaign_impressions(campaign_id, created_at); -- Analytics range scans
+CREATE INDEX IF NOT EXISTS idx_impressions_created ON campaign_impressions(created_at);   -- Rollup of whole hours
+
+-- Serving index for the pure-PostgreSQL repository (active lookup by priority)
+CREATE INDEX IF NOT EXISTS idx_campaign_serve ON campaigns (is_active, priority DESC, start_time, end_time);
+
+-- Migrations for existing databases
+DO $$
+BEGIN
+    -- max_frequency = 0 used to mean "never show" and now means "no lifetime cap". Runs once, on the
+    -- upgrade that adds frequency caps: such campaigns never served, so they are deactivated instead
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'campaigns' AND column_name = 'cap_per_day') THEN
+        UPDATE campaigns SET is_active = false WHERE max_frequency = 0;
+    END IF;
+END $$;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS cap_per_day INT DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS cap_per_week INT DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS target_segment VARCHAR(64);
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS target_rules JSONB;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS click_cap_per_day INT DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS click_cap_per_week INT DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS click_cap_lifetime INT DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS dismiss_hides BOOLEAN DEFAULT false;
+DROP INDEX IF EXISTS idx_impressions_user_window; -- Superseded by idx_impressions_user_action
+ALTER TABLE campaign_impressions ADD COLUMN IF NOT EXISTS inserted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+CREATE INDEX IF NOT EXISTS idx_impressions_inserted ON campaign_impressions(inserted_at); -- Late event detection (after the column exists)
+ALTER TABLE campaign_impressions ADD COLUMN IF NOT EXISTS event_id VARCHAR(128);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_impressions_event ON campaign_impressions(user_id, event_id) WHERE event_id IS NOT NULL;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS placement VARCHAR(32) NOT NULL DEFAULT 'default';
+CREATE INDEX IF NOT EXISTS idx_campaign_placement ON campaigns (placement, is_active, priority DESC); -- Per-placement serving (after the column exists)
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS weight INT DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS variants JSONB;
+ALTER TABLE campaign_impressions ADD COLUMN IF NOT EXISTS variant_id VARCHAR(32) NOT NULL DEFAULT '';
+DO $$
+BEGIN
+    -- Rollups gain the variant dimension; existing rows belong to no experiment ('')
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'campaign_metrics_hourly' AND column_name = 'variant_id') THEN
+        ALTER TABLE campaign_metrics_hourly ADD COLUMN variant_id VARCHAR(32) NOT NULL DEFAULT '',
+            DROP CONSTRAINT campaign_metrics_hourly_pkey, ADD PRIMARY KEY (campaign_id, variant_id, hour);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'campaign_viewers_hourly' AND column_name = 'variant_id') THEN
+        ALTER TABLE campaign_viewers_hourly ADD COLUMN variant_id VARCHAR(32) NOT NULL DEFAULT '',
+            DROP CONSTRAINT campaign_viewers_hourly_pkey, ADD PRIMARY KEY (campaign_id, variant_id, hour, user_id);
+    END IF;
+END $$;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS budget BIGINT DEFAULT 0;
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS pacing VARCHAR(10);
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS schedule JSONB;
+DO $$
+BEGIN
+    -- A segment cannot be deleted while a campaign references it. NOT VALID skips rows written
+    -- before the constraint existed; every new or updated row is checked
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'campaigns_target_segment_fkey') THEN
+        ALTER TABLE campaigns ADD CONSTRAINT campaigns_target_segment_fkey
+            FOREIGN KEY (target_segment) REFERENCES segments(name) ON DELETE RESTRICT NOT VALID;
+    END IF;
+END $$;
+DO $$
+BEGIN
+    -- Deleting a campaign deletes its events; it used to fail once any were recorded. The old
+    -- constraint already checked every row, so NOT VALID skips a full scan of the events
+    IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'campaign_impressions_campaign_id_fkey' AND confdeltype <> 'c') THEN
+        ALTER TABLE campaign_impressions DROP CONSTRAINT campaign_impressions_campaign_id_fkey,
+            ADD CONSTRAINT campaign_impressions_campaign_id_fkey
+                FOREIGN KEY (campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE NOT VALID;
+    END IF;
+END $$;
@@ -15,6 +15,64 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/analytics/campaigns": {
+            "get": {
+                "description": "Impressions, unique reach, clicks, CTR and dismiss rate per campaign, with totals and an hourly or daily series (gap-free, for charts).\nDefaults to the last 24 hours by hour, or the last 30 days by day.\nCampaigns that ran an A/B experiment also get totals per variant.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Analytics"
+                ],
+                "summary": "Campaign Performance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Campaign IDs, repeated or comma-separated (default all)",
+                        "name": "campaign_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range start, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range end (exclusive), RFC 3339 (default now)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "hour (default) or day",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone for bucket boundaries, whole-hour offsets only (default UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.AnalyticsReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/admin/campaigns": {
             "get": {
                 "description": "Fetches all campaigns from PostgreSQL.",
@@ -102,7 +160,7 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Deletes a campaign from DB and Redis.",
+                "description": "Deletes a campaign from DB and Redis, with its events and analytics.",
                 "tags": [
                     "Admin"
                 ],
@@ -152,54 +210,218 @@ const docTemplate = `{
                 }
             }
         },
-        "/debug/sync": {
-            "post": {
-                "description": "Manually triggers synchronization of all campaigns from DB to Redis.",
+        "/admin/campaigns/targets": {
+            "get": {
+                "description": "Lists targeted user IDs of a SEGMENT campaign from PostgreSQL, ascending, paginated by user ID.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "Debug"
+                    "Admin"
+                ],
+                "summary": "List Segment Targets",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Campaign ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return user IDs greater than this",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 1000, max 10000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Sync DB to Redis",
                 "responses": {
                     "200": {
-                        "description": "Synced"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.TargetsResponse"
+                        }
                     }
                 }
-            }
-        },
-        "/v1/campaigns/impression": {
+            },
+            "put": {
+                "description": "Replaces the whole target list of a SEGMENT campaign in DB and Redis bitmap.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Replace Segment Targets",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Campaign ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "User IDs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.TargetsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
             "post": {
-                "description": "Records that a user has seen a campaign.",
+                "description": "Whitelists users for a SEGMENT campaign in DB and Redis bitmap.",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Add Segment Targets",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Campaign ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "User IDs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.TargetsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes users from a SEGMENT campaign in DB and Redis bitmap.",
+                "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "Client"
+                    "Admin"
                 ],
-                "summary": "Track Impression",
+                "summary": "Remove Segment Targets",
                 "parameters": [
                     {
-                        "description": "Impression Request",
+                        "type": "integer",
+                        "description": "Campaign ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "User IDs",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/main.ImpressionRequest"
+                            "$ref": "#/definitions/main.TargetsRequest"
                         }
                     }
                 ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/admin/campaigns/targets/upload": {
+            "post": {
+                "description": "Streams a CSV of user_id (first column, optional \"user_id\" header) into a SEGMENT campaign, in batches to DB and Redis bitmap. Invalid lines are skipped and reported.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Upload Segment Targets (CSV)",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Campaign ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "replace (default) or append",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "file",
+                        "description": "CSV file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "No valid rows",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.ImportReport"
+                        }
                     }
                 }
             }
         },
-        "/v1/campaigns/popup": {
+        "/admin/policy": {
             "get": {
-                "description": "Determines the best campaign popup for a user based on priority, time, and targeting.",
+                "description": "Returns the policy limiting how often one user sees popups across all campaigns. Empty when none was ever set.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Policy"
+                ],
+                "summary": "Get User Policy",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.UserPolicy"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replaces the user policy in DB and Redis: a cooldown after any popup was seen and daily/weekly limits across all campaigns,\non top of each campaign's own caps. Zero disables a limit, so {} turns the policy off.",
                 "consumes": [
                     "application/json"
                 ],
@@ -207,9 +429,46 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Client"
+                    "Policy"
                 ],
-                "summary": "Get Popup for User",
+                "summary": "Set User Policy",
+                "parameters": [
+                    {
+                        "description": "Policy (cooldown_seconds, per_day, per_week)",
+                        "name": "policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/campaign.UserPolicy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.UserPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid policy",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/popup/explain": {
+            "get": {
+                "description": "Runs the GetPopup evaluation for a user and lists every active campaign of the placement, in priority order, with the reason it was rejected\n(missing_metadata, not_started, ended, off_schedule, not_targeted, rules_not_matched, frequency_cap_reached, click_cap_reached, dismissed, budget_exhausted, ahead_of_pace, outranked) and which one won.\nWhen the user policy blocks the user (user_cooldown, user_daily_limit, user_weekly_limit), \"blocked\" is set and every otherwise eligible campaign carries that reason.\nNothing is recorded. Takes the same request context as the popup endpoint.\nPass \"at\" to simulate the decision at another instant (e.g. before a campaign starts).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Explain Popup Decision",
                 "parameters": [
                     {
                         "type": "integer",
@@ -217,18 +476,57 @@ const docTemplate = `{
                         "name": "user_id",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Placement, e.g. home_modal (default: default)",
+                        "name": "placement",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client platform (ios, android, web)",
+                        "name": "platform",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "App version (e.g. 5.12.1)",
+                        "name": "app_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO country code",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language tag (e.g. en-US)",
+                        "name": "language",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Account tier",
+                        "name": "tier",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Evaluate at this instant, RFC 3339 (default now)",
+                        "name": "at",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/campaign.Campaign"
+                            "$ref": "#/definitions/campaign.Decision"
                         }
                     },
-                    "204": {
-                        "description": "No Content (No suitable campaign)"
-                    },
                     "400": {
                         "description": "Invalid User ID",
                         "schema": {
@@ -237,34 +535,1150 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "campaign.Campaign": {
-            "type": "object",
-            "properties": {
-                "action_url": {
-                    "type": "string"
-                },
-                "end_time": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "image_url": {
-                    "type": "string"
-                },
-                "is_active": {
-                    "description": "For DB/Admin",
-                    "type": "boolean"
-                },
+        },
+        "/admin/segments": {
+            "get": {
+                "description": "Lists named, reusable segments from PostgreSQL.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "List Segments",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/campaign.Segment"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a named segment campaigns can reference via target_segment.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Create Segment",
+                "parameters": [
+                    {
+                        "description": "Segment (name, description)",
+                        "name": "segment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/campaign.Segment"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.Segment"
+                        }
+                    },
+                    "409": {
+                        "description": "Segment already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes a segment and its members from DB and Redis. Fails while campaigns still reference it.",
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Delete Segment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment name",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "409": {
+                        "description": "Segment is referenced by campaigns",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/segments/members": {
+            "get": {
+                "description": "Lists user IDs of a named segment from PostgreSQL, ascending, paginated by user ID.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "List Segment Members",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment name",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return user IDs greater than this",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 1000, max 10000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/main.TargetsResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replaces all members of a named segment in DB and Redis bitmap.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Replace Segment Members",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment name",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "User IDs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.TargetsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds users to a named segment in DB and Redis bitmap.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Add Segment Members",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment name",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "User IDs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.TargetsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes users from a named segment in DB and Redis bitmap.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Remove Segment Members",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment name",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "description": "User IDs",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.TargetsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/admin/segments/members/upload": {
+            "post": {
+                "description": "Streams a CSV of user_id (first column, optional \"user_id\" header) into a named segment, in batches to DB and Redis bitmap. Invalid lines are skipped and reported.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Segments"
+                ],
+                "summary": "Upload Segment Members (CSV)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Segment name",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "replace (default) or append",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "file",
+                        "description": "CSV file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "No valid rows",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.ImportReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/events/status": {
+            "get": {
+                "description": "Reports queue depth, written/dropped counts and failures of the asynchronous event log writer.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Debug"
+                ],
+                "summary": "Event Writer Status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.EventWriterStatus"
+                        }
+                    },
+                    "404": {
+                        "description": "Event writer not running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/rollup/status": {
+            "get": {
+                "description": "Reports the watermark (hours before it are served from rollups), lag and failures of the hourly event rollup.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Debug"
+                ],
+                "summary": "Rollup Worker Status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.RollupStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/sync": {
+            "post": {
+                "description": "Reconciles Redis with the DB (adds missing, refreshes stale, removes orphaned campaigns, restores missing target bitmaps) and returns the diff.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Debug"
+                ],
+                "summary": "Sync DB to Redis",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only report the diff, do not apply it",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.SyncReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/sync/status": {
+            "get": {
+                "description": "Reports lag and failures of the background DB to Redis sync.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Debug"
+                ],
+                "summary": "Sync Worker Status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.SyncStatus"
+                        }
+                    },
+                    "404": {
+                        "description": "Sync worker not running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/campaigns/events": {
+            "post": {
+                "description": "Records a VIEW, CLICK or DISMISS in the event log. Views count toward the frequency cap,\nclicks toward the click cap, and a dismiss hides the campaign when its dismiss_hides is set.\nSend an event_id to make retries safe: a repeated ID is acknowledged but not counted again.\nA serve token counts one event per action; it is acknowledged but not counted when sent again.\nFor A/B campaigns the variant the popup showed is recorded with the event: the one signed into the serve token,\nor the echoed variant_id when tokens are disabled.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Client"
+                ],
+                "summary": "Track Event",
+                "parameters": [
+                    {
+                        "description": "Event Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.EventRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Invalid action",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid serve token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/campaigns/impression": {
+            "post": {
+                "description": "Records that a user has seen a campaign. Same as an events call with action VIEW.\nSend an event_id to make retries safe: a repeated ID is acknowledged but not counted again.\nA serve token counts one event per action; it is acknowledged but not counted when sent again.\nA campaign that is not live (no cached metadata) returns 404 and is not counted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Client"
+                ],
+                "summary": "Track Impression",
+                "parameters": [
+                    {
+                        "description": "Impression Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ImpressionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "403": {
+                        "description": "Invalid serve token",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/campaigns/popup": {
+            "get": {
+                "description": "Determines the best campaign popup for a user based on priority, time, and targeting.\nEach placement (surface) is resolved independently; without one the \"default\" placement is used.\nNothing is returned while the user policy (cooldown, daily/weekly limits across campaigns) blocks the user.\nRequest context for RULES campaigns comes from the query, falling back to headers\n(X-Platform, X-App-Version, X-Country, Accept-Language, X-Account-Tier).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Client"
+                ],
+                "summary": "Get Popup for User",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Placement, e.g. home_modal (default: default)",
+                        "name": "placement",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client platform (ios, android, web)",
+                        "name": "platform",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "App version (e.g. 5.12.1)",
+                        "name": "app_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO country code",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language tag (e.g. en-US)",
+                        "name": "language",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Account tier",
+                        "name": "tier",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/campaign.Popup"
+                        }
+                    },
+                    "204": {
+                        "description": "No Content (No suitable campaign)"
+                    },
+                    "400": {
+                        "description": "Invalid User ID or placement",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/campaigns/popups": {
+            "get": {
+                "description": "Returns up to \"limit\" eligible campaigns of a placement in priority order, e.g. for a carousel.\nSame checks and request context as the popup endpoint; each popup has its own serve token.\nWhile the user policy has a cooldown, at most one popup is returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Client"
+                ],
+                "summary": "Get Several Popups for User",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of popups (1-20, default 5)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Placement, e.g. home_modal (default: default)",
+                        "name": "placement",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client platform (ios, android, web)",
+                        "name": "platform",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "App version (e.g. 5.12.1)",
+                        "name": "app_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO country code",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Language tag (e.g. en-US)",
+                        "name": "language",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Account tier",
+                        "name": "tier",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/campaign.Popup"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid User ID, limit or placement",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "campaign.AnalyticsReport": {
+            "type": "object",
+            "properties": {
+                "campaigns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.CampaignMetrics"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "granularity": {
+                    "$ref": "#/definitions/campaign.Granularity"
+                },
+                "timezone": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "campaign.Campaign": {
+            "type": "object",
+            "properties": {
+                "action_url": {
+                    "type": "string"
+                },
+                "budget": {
+                    "description": "Total impressions across all users (0 = unlimited)",
+                    "type": "integer"
+                },
+                "click_cap": {
+                    "description": "Stop showing after this many clicks",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.FrequencyCap"
+                        }
+                    ]
+                },
+                "dismiss_hides": {
+                    "description": "A DISMISS hides the campaign from that user for good",
+                    "type": "boolean"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "frequency_cap": {
+                    "$ref": "#/definitions/campaign.FrequencyCap"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "is_active": {
+                    "description": "For DB/Admin",
+                    "type": "boolean"
+                },
+                "max_frequency": {
+                    "description": "Legacy lifetime cap, used when FrequencyCap.Lifetime is 0",
+                    "type": "integer"
+                },
+                "pacing": {
+                    "description": "How fast Budget may be spent (default asap)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.Pacing"
+                        }
+                    ]
+                },
+                "placement": {
+                    "description": "Surface the campaign competes on, see DefaultPlacement",
+                    "type": "string"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "rules": {
+                    "description": "If TargetType == RULES",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.Rule"
+                    }
+                },
+                "schedule": {
+                    "description": "Recurring slots within StartTime and EndTime (nil = always)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.Schedule"
+                        }
+                    ]
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "target_segment": {
+                    "description": "If TargetType == SEGMENT",
+                    "type": "string"
+                },
+                "target_type": {
+                    "$ref": "#/definitions/campaign.TargetType"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "variants": {
+                    "description": "A/B creatives, one assigned per user",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.Variant"
+                    }
+                },
+                "weight": {
+                    "description": "Share within its priority tier under rotation (0 = 1)",
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.CampaignMetrics": {
+            "type": "object",
+            "properties": {
+                "campaign_id": {
+                    "type": "integer"
+                },
+                "series": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.MetricsPoint"
+                    }
+                },
+                "totals": {
+                    "$ref": "#/definitions/campaign.Metrics"
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.VariantMetrics"
+                    }
+                }
+            }
+        },
+        "campaign.Candidate": {
+            "type": "object",
+            "properties": {
+                "campaign_id": {
+                    "type": "integer"
+                },
+                "clicks": {
+                    "$ref": "#/definitions/campaign.ImpressionCounts"
+                },
+                "delivered": {
+                    "description": "Campaign-wide impressions, when it has a budget",
+                    "type": "integer"
+                },
+                "dismissed": {
+                    "type": "boolean"
+                },
+                "impressions": {
+                    "$ref": "#/definitions/campaign.ImpressionCounts"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "description": "Empty for the winner",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.RejectReason"
+                        }
+                    ]
+                },
+                "targeted": {
+                    "type": "boolean"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "variant_id": {
+                    "description": "Variant the user is assigned to",
+                    "type": "string"
+                },
+                "weight": {
+                    "type": "integer"
+                },
+                "won": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "campaign.Decision": {
+            "type": "object",
+            "properties": {
+                "activity": {
+                    "$ref": "#/definitions/campaign.UserActivity"
+                },
+                "at": {
+                    "type": "string"
+                },
+                "blocked": {
+                    "description": "User policy blocks every campaign",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.RejectReason"
+                        }
+                    ]
+                },
+                "candidates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.Candidate"
+                    }
+                },
+                "context": {
+                    "$ref": "#/definitions/campaign.RequestContext"
+                },
+                "placement": {
+                    "type": "string"
+                },
+                "policy": {
+                    "$ref": "#/definitions/campaign.UserPolicy"
+                },
+                "simulated": {
+                    "description": "Evaluated at a requested instant, not now",
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "winner_id": {
+                    "description": "0 = nothing would be shown",
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.EventAction": {
+            "type": "string",
+            "enum": [
+                "VIEW",
+                "CLICK",
+                "DISMISS"
+            ],
+            "x-enum-varnames": [
+                "ActionView",
+                "ActionClick",
+                "ActionDismiss"
+            ]
+        },
+        "campaign.EventWriterStatus": {
+            "type": "object",
+            "properties": {
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "dropped": {
+                    "description": "Buffer full, or retry backlog over its limit",
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_flush": {
+                    "type": "string"
+                },
+                "pending": {
+                    "description": "Picked up, waiting for a (re)try",
+                    "type": "integer"
+                },
+                "queued": {
+                    "description": "In the channel, not yet picked up",
+                    "type": "integer"
+                },
+                "rejected": {
+                    "description": "Refused by the Store as invalid, never retried",
+                    "type": "integer"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "written": {
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.FrequencyCap": {
+            "type": "object",
+            "properties": {
+                "lifetime": {
+                    "type": "integer"
+                },
+                "per_day": {
+                    "type": "integer"
+                },
+                "per_week": {
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.Granularity": {
+            "type": "string",
+            "enum": [
+                "hour",
+                "day"
+            ],
+            "x-enum-varnames": [
+                "GranularityHour",
+                "GranularityDay"
+            ]
+        },
+        "campaign.HourRange": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "campaign.ImportReport": {
+            "type": "object",
+            "properties": {
+                "accepted": {
+                    "type": "integer"
+                },
+                "campaign_id": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "description": "\"replace\" or \"append\"",
+                    "type": "string"
+                },
+                "rejected": {
+                    "type": "integer"
+                },
+                "rejected_lines": {
+                    "description": "First 100 only",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.RejectedLine"
+                    }
+                },
+                "segment": {
+                    "type": "string"
+                }
+            }
+        },
+        "campaign.ImpressionCounts": {
+            "type": "object",
+            "properties": {
+                "day": {
+                    "type": "integer"
+                },
+                "lifetime": {
+                    "type": "integer"
+                },
+                "week": {
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.Metrics": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "ctr": {
+                    "description": "Clicks / impressions",
+                    "type": "number"
+                },
+                "dismiss_rate": {
+                    "description": "Dismisses / impressions",
+                    "type": "number"
+                },
+                "dismisses": {
+                    "type": "integer"
+                },
+                "impressions": {
+                    "type": "integer"
+                },
+                "unique_reach": {
+                    "description": "Distinct users with a VIEW",
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.MetricsPoint": {
+            "type": "object",
+            "properties": {
+                "bucket": {
+                    "type": "string"
+                },
+                "clicks": {
+                    "type": "integer"
+                },
+                "ctr": {
+                    "description": "Clicks / impressions",
+                    "type": "number"
+                },
+                "dismiss_rate": {
+                    "description": "Dismisses / impressions",
+                    "type": "number"
+                },
+                "dismisses": {
+                    "type": "integer"
+                },
+                "impressions": {
+                    "type": "integer"
+                },
+                "unique_reach": {
+                    "description": "Distinct users with a VIEW",
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.Pacing": {
+            "type": "string",
+            "enum": [
+                "asap",
+                "even"
+            ],
+            "x-enum-comments": {
+                "PacingASAP": "As fast as traffic allows, until the budget is spent (default)",
+                "PacingEven": "Evenly between StartTime and EndTime"
+            },
+            "x-enum-descriptions": [
+                "As fast as traffic allows, until the budget is spent (default)",
+                "Evenly between StartTime and EndTime"
+            ],
+            "x-enum-varnames": [
+                "PacingASAP",
+                "PacingEven"
+            ]
+        },
+        "campaign.Popup": {
+            "type": "object",
+            "properties": {
+                "action_url": {
+                    "type": "string"
+                },
+                "budget": {
+                    "description": "Total impressions across all users (0 = unlimited)",
+                    "type": "integer"
+                },
+                "click_cap": {
+                    "description": "Stop showing after this many clicks",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.FrequencyCap"
+                        }
+                    ]
+                },
+                "dismiss_hides": {
+                    "description": "A DISMISS hides the campaign from that user for good",
+                    "type": "boolean"
+                },
+                "end_time": {
+                    "type": "string"
+                },
+                "frequency_cap": {
+                    "$ref": "#/definitions/campaign.FrequencyCap"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "is_active": {
+                    "description": "For DB/Admin",
+                    "type": "boolean"
+                },
                 "max_frequency": {
+                    "description": "Legacy lifetime cap, used when FrequencyCap.Lifetime is 0",
                     "type": "integer"
                 },
+                "pacing": {
+                    "description": "How fast Budget may be spent (default asap)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.Pacing"
+                        }
+                    ]
+                },
+                "placement": {
+                    "description": "Surface the campaign competes on, see DefaultPlacement",
+                    "type": "string"
+                },
                 "priority": {
                     "type": "integer"
                 },
+                "rules": {
+                    "description": "If TargetType == RULES",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.Rule"
+                    }
+                },
+                "schedule": {
+                    "description": "Recurring slots within StartTime and EndTime (nil = always)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.Schedule"
+                        }
+                    ]
+                },
+                "serve_token": {
+                    "type": "string"
+                },
                 "start_time": {
                     "type": "string"
                 },
@@ -277,6 +1691,305 @@ const docTemplate = `{
                 },
                 "title": {
                     "type": "string"
+                },
+                "variant_id": {
+                    "description": "A/B variant shown, its creative is already applied",
+                    "type": "string"
+                },
+                "variants": {
+                    "description": "A/B creatives, one assigned per user",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.Variant"
+                    }
+                },
+                "weight": {
+                    "description": "Share within its priority tier under rotation (0 = 1)",
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.RejectReason": {
+            "type": "string",
+            "enum": [
+                "missing_metadata",
+                "not_started",
+                "ended",
+                "off_schedule",
+                "not_targeted",
+                "rules_not_matched",
+                "frequency_cap_reached",
+                "click_cap_reached",
+                "dismissed",
+                "budget_exhausted",
+                "ahead_of_pace",
+                "outranked",
+                "user_cooldown",
+                "user_daily_limit",
+                "user_weekly_limit"
+            ],
+            "x-enum-comments": {
+                "RejectAheadOfPace": "Even pacing: delivered more than the schedule allows yet",
+                "RejectBudgetSpent": "Impression budget delivered",
+                "RejectCapReached": "Impressions per day, week or lifetime",
+                "RejectEnded": "After end_time",
+                "RejectMissingMetadata": "Active in the ZSET but no campaign:{id}:meta",
+                "RejectNotStarted": "Before start_time",
+                "RejectNotTargeted": "SEGMENT campaign, user not in the audience",
+                "RejectOffSchedule": "Between start_time and end_time, outside the recurring schedule",
+                "RejectOutranked": "Eligible, but a higher priority campaign won",
+                "RejectRulesNotMatched": "RULES campaign, request context does not match",
+                "RejectUserCooldown": "Saw a popup less than cooldown_seconds ago",
+                "RejectUserDailyLimit": "Popups seen today, across all campaigns",
+                "RejectUserWeeklyLimit": "Popups seen this week, across all campaigns"
+            },
+            "x-enum-descriptions": [
+                "Active in the ZSET but no campaign:{id}:meta",
+                "Before start_time",
+                "After end_time",
+                "Between start_time and end_time, outside the recurring schedule",
+                "SEGMENT campaign, user not in the audience",
+                "RULES campaign, request context does not match",
+                "Impressions per day, week or lifetime",
+                "",
+                "",
+                "Impression budget delivered",
+                "Even pacing: delivered more than the schedule allows yet",
+                "Eligible, but a higher priority campaign won",
+                "Saw a popup less than cooldown_seconds ago",
+                "Popups seen today, across all campaigns",
+                "Popups seen this week, across all campaigns"
+            ],
+            "x-enum-varnames": [
+                "RejectMissingMetadata",
+                "RejectNotStarted",
+                "RejectEnded",
+                "RejectOffSchedule",
+                "RejectNotTargeted",
+                "RejectRulesNotMatched",
+                "RejectCapReached",
+                "RejectClickCapReached",
+                "RejectDismissed",
+                "RejectBudgetSpent",
+                "RejectAheadOfPace",
+                "RejectOutranked",
+                "RejectUserCooldown",
+                "RejectUserDailyLimit",
+                "RejectUserWeeklyLimit"
+            ]
+        },
+        "campaign.RejectedLine": {
+            "type": "object",
+            "properties": {
+                "line": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "campaign.RequestContext": {
+            "type": "object",
+            "properties": {
+                "app_version": {
+                    "type": "string"
+                },
+                "country": {
+                    "type": "string"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "platform": {
+                    "type": "string"
+                },
+                "tier": {
+                    "type": "string"
+                }
+            }
+        },
+        "campaign.RollupReport": {
+            "type": "object",
+            "properties": {
+                "late_hours": {
+                    "description": "Already rolled-up hours rebuilt because late events arrived",
+                    "type": "integer"
+                },
+                "new_hours": {
+                    "description": "Hours rolled up for the first time",
+                    "type": "integer"
+                },
+                "watermark": {
+                    "description": "Hours before this are served from the rollups",
+                    "type": "string"
+                }
+            }
+        },
+        "campaign.RollupStatus": {
+            "type": "object",
+            "properties": {
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "lag_seconds": {
+                    "description": "Time since the watermark, i.e. size of the raw tail",
+                    "type": "number"
+                },
+                "last_attempt": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_report": {
+                    "$ref": "#/definitions/campaign.RollupReport"
+                },
+                "last_success": {
+                    "type": "string"
+                },
+                "running": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "campaign.Rule": {
+            "type": "object",
+            "properties": {
+                "attribute": {
+                    "type": "string"
+                },
+                "operator": {
+                    "type": "string"
+                },
+                "values": {
+                    "description": "Exactly one for gte / lt",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "campaign.Schedule": {
+            "type": "object",
+            "properties": {
+                "hours": {
+                    "description": "Any range may match",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/campaign.HourRange"
+                    }
+                },
+                "month_days": {
+                    "description": "1 to 31, or LastMonthDay",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "timezone": {
+                    "description": "IANA name, e.g. \"Europe/Berlin\" (default UTC)",
+                    "type": "string"
+                },
+                "weekdays": {
+                    "description": "\"mon\" to \"sun\"",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "campaign.Segment": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "campaign.SyncReport": {
+            "type": "object",
+            "properties": {
+                "audiences": {
+                    "description": "Target bitmaps of live campaigns absent from cache, e.g. \"segment:vip\"",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "missing": {
+                    "description": "Live in DB, absent from cache",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "orphaned": {
+                    "description": "Cached but deleted, inactive or expired in DB",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "stale": {
+                    "description": "Cached with outdated metadata, priority or placement",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "unchanged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.SyncStatus": {
+            "type": "object",
+            "properties": {
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "lag_seconds": {
+                    "description": "Time since last successful sync",
+                    "type": "number"
+                },
+                "last_attempt": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_report": {
+                    "$ref": "#/definitions/campaign.SyncReport"
+                },
+                "last_success": {
+                    "type": "string"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "total_failures": {
+                    "type": "integer"
                 }
             }
         },
@@ -284,21 +1997,189 @@ const docTemplate = `{
             "type": "string",
             "enum": [
                 "ALL",
-                "SEGMENT"
+                "SEGMENT",
+                "RULES"
+            ],
+            "x-enum-comments": {
+                "TargetTypeRules": "Matched against the request context, see Rules"
+            },
+            "x-enum-descriptions": [
+                "",
+                "",
+                "Matched against the request context, see Rules"
             ],
             "x-enum-varnames": [
                 "TargetTypeAll",
-                "TargetTypeSegment"
+                "TargetTypeSegment",
+                "TargetTypeRules"
             ]
         },
+        "campaign.UserActivity": {
+            "type": "object",
+            "properties": {
+                "day": {
+                    "type": "integer"
+                },
+                "last_seen": {
+                    "description": "Zero = nothing seen recently",
+                    "type": "string"
+                },
+                "week": {
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.UserPolicy": {
+            "type": "object",
+            "properties": {
+                "cooldown_seconds": {
+                    "description": "Minimum gap after a popup was seen",
+                    "type": "integer"
+                },
+                "per_day": {
+                    "type": "integer"
+                },
+                "per_week": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "campaign.Variant": {
+            "type": "object",
+            "properties": {
+                "action_url": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "weight": {
+                    "description": "Traffic share relative to the other variants; 0 pauses the variant",
+                    "type": "integer"
+                }
+            }
+        },
+        "campaign.VariantMetrics": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "ctr": {
+                    "description": "Clicks / impressions",
+                    "type": "number"
+                },
+                "dismiss_rate": {
+                    "description": "Dismisses / impressions",
+                    "type": "number"
+                },
+                "dismisses": {
+                    "type": "integer"
+                },
+                "impressions": {
+                    "type": "integer"
+                },
+                "unique_reach": {
+                    "description": "Distinct users with a VIEW",
+                    "type": "integer"
+                },
+                "variant_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.EventRequest": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "VIEW, CLICK or DISMISS",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/campaign.EventAction"
+                        }
+                    ]
+                },
+                "campaign_id": {
+                    "type": "integer"
+                },
+                "event_id": {
+                    "description": "Client-generated; retries with the same ID are counted once",
+                    "type": "string"
+                },
+                "serve_token": {
+                    "description": "From the popup response; required when tokens are enabled",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "variant_id": {
+                    "description": "From the popup response; taken from the serve token when tokens are enabled",
+                    "type": "string"
+                }
+            }
+        },
         "main.ImpressionRequest": {
             "type": "object",
             "properties": {
                 "campaign_id": {
                     "type": "integer"
                 },
+                "event_id": {
+                    "description": "Client-generated; retries with the same ID are counted once",
+                    "type": "string"
+                },
+                "serve_token": {
+                    "description": "From the popup response; required when tokens are enabled",
+                    "type": "string"
+                },
                 "user_id": {
                     "type": "integer"
+                },
+                "variant_id": {
+                    "description": "From the popup response; taken from the serve token when tokens are enabled",
+                    "type": "string"
+                }
+            }
+        },
+        "main.TargetsRequest": {
+            "type": "object",
+            "properties": {
+                "user_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "main.TargetsResponse": {
+            "type": "object",
+            "properties": {
+                "campaign_id": {
+                    "type": "integer"
+                },
+                "next_after": {
+                    "description": "Pass as \"after\" to get the next page",
+                    "type": "integer"
+                },
+                "segment": {
+                    "type": "string"
+                },
+                "user_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
                 }
             }
         }
@@ -0,0 +1,225 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrInvalidAnalyticsQuery = errors.New("invalid analytics query")
+
+type Granularity string
+
+const (
+	GranularityHour Granularity = "hour"
+	GranularityDay  Granularity = "day"
+)
+
+// Upper bounds on the number of buckets per series, to keep responses chart-sized.
+const (
+	maxHourBuckets = 31 * 24
+	maxDayBuckets  = 366
+)
+
+// AnalyticsQuery selects events by campaign and time range [From, To).
+type AnalyticsQuery struct {
+	CampaignIDs []int64 // Empty = all campaigns
+	From        time.Time
+	To          time.Time
+	Granularity Granularity
+	Location    *time.Location // Bucket boundaries (day starts at local midnight)
+}
+
+// Metrics aggregates events of one campaign over a bucket or the whole range.
+type Metrics struct {
+	Impressions int64   `json:"impressions"`
+	UniqueReach int64   `json:"unique_reach"` // Distinct users with a VIEW
+	Clicks      int64   `json:"clicks"`
+	Dismisses   int64   `json:"dismisses"`
+	CTR         float64 `json:"ctr"`          // Clicks / impressions
+	DismissRate float64 `json:"dismiss_rate"` // Dismisses / impressions
+}
+
+// MetricsRow is one aggregate read from the Store; Bucket is zero for range totals,
+// VariantID is only set by MetricsVariantTotals.
+type MetricsRow struct {
+	CampaignID int64
+	VariantID  string
+	Bucket     time.Time
+	Metrics
+}
+
+type MetricsPoint struct {
+	Bucket time.Time `json:"bucket"`
+	Metrics
+}
+
+// VariantMetrics compares the arms of an A/B experiment over the whole range.
+type VariantMetrics struct {
+	VariantID string `json:"variant_id"`
+	Metrics
+}
+
+// CampaignMetrics is the chart data of one campaign: totals plus a gap-free series,
+// and totals per variant when the campaign ran an experiment in the range.
+type CampaignMetrics struct {
+	CampaignID int64            `json:"campaign_id"`
+	Totals     Metrics          `json:"totals"`
+	Variants   []VariantMetrics `json:"variants,omitempty"`
+	Series     []MetricsPoint   `json:"series"`
+}
+
+// AnalyticsReport answers an AnalyticsQuery.
+type AnalyticsReport struct {
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Granularity Granularity        `json:"granularity"`
+	Timezone    string             `json:"timezone"`
+	Campaigns   []*CampaignMetrics `json:"campaigns"`
+}
+
+// GetAnalytics aggregates the event log per campaign, in hourly or daily buckets.
+// Campaigns without events in the range are omitted.
+func (s *Service) GetAnalytics(ctx context.Context, q AnalyticsQuery) (*AnalyticsReport, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+
+	series, err := s.store.MetricsSeries(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.store.MetricsTotals(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := s.store.MetricsVariantTotals(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[int64]*CampaignMetrics{}
+	get := func(id int64) *CampaignMetrics {
+		cm, ok := byID[id]
+		if !ok {
+			cm = &CampaignMetrics{CampaignID: id}
+			byID[id] = cm
+		}
+		return cm
+	}
+	for _, row := range totals {
+		get(row.CampaignID).Totals = row.Metrics.withRates()
+	}
+	for _, row := range variants {
+		if row.VariantID == "" {
+			continue // Traffic outside any experiment
+		}
+		cm := get(row.CampaignID)
+		cm.Variants = append(cm.Variants, VariantMetrics{VariantID: row.VariantID, Metrics: row.Metrics.withRates()})
+	}
+	filled := map[int64]map[int64]Metrics{} // Campaign -> bucket start (Unix) -> metrics
+	for _, row := range series {
+		get(row.CampaignID)
+		if filled[row.CampaignID] == nil {
+			filled[row.CampaignID] = map[int64]Metrics{}
+		}
+		filled[row.CampaignID][row.Bucket.Unix()] = row.Metrics.withRates()
+	}
+
+	buckets := q.buckets()
+	report := &AnalyticsReport{
+		From:        q.From,
+		To:          q.To,
+		Granularity: q.Granularity,
+		Timezone:    q.Location.String(),
+		Campaigns:   make([]*CampaignMetrics, 0, len(byID)),
+	}
+	for id, cm := range byID {
+		cm.Series = make([]MetricsPoint, len(buckets))
+		for i, b := range buckets {
+			cm.Series[i] = MetricsPoint{Bucket: b, Metrics: filled[id][b.Unix()]}
+		}
+		report.Campaigns = append(report.Campaigns, cm)
+	}
+	sort.Slice(report.Campaigns, func(i, j int) bool {
+		return report.Campaigns[i].CampaignID < report.Campaigns[j].CampaignID
+	})
+	return report, nil
+}
+
+func (m Metrics) withRates() Metrics {
+	if m.Impressions > 0 {
+		m.CTR = float64(m.Clicks) / float64(m.Impressions)
+		m.DismissRate = float64(m.Dismisses) / float64(m.Impressions)
+	}
+	return m
+}
+
+// normalize applies defaults and aligns the range to bucket boundaries.
+func (q *AnalyticsQuery) normalize() error {
+	if q.Location == nil {
+		q.Location = time.UTC
+	}
+	switch q.Granularity {
+	case "":
+		q.Granularity = GranularityHour
+	case GranularityHour, GranularityDay:
+	default:
+		return fmt.Errorf("%w: granularity must be hour or day", ErrInvalidAnalyticsQuery)
+	}
+	if q.From.IsZero() || q.To.IsZero() || !q.From.Before(q.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidAnalyticsQuery)
+	}
+
+	q.From = q.truncate(q.From)
+	if end := q.truncate(q.To); end.Before(q.To) {
+		q.To = q.next(end) // Include the partial last bucket
+	}
+
+	limit := maxHourBuckets
+	if q.Granularity == GranularityDay {
+		limit = maxDayBuckets
+	}
+	buckets := q.buckets()
+	if n := len(buckets); n > limit {
+		return fmt.Errorf("%w: %d %s buckets requested, at most %d", ErrInvalidAnalyticsQuery, n, q.Granularity, limit)
+	}
+	// Rollups are whole UTC hours, so a bucket starting off the hour (Asia/Kolkata, UTC+5:30)
+	// would move rolled-up traffic into the wrong bucket
+	for _, b := range buckets {
+		if b.Unix()%3600 != 0 {
+			return fmt.Errorf("%w: time zone %s is not a whole number of hours from UTC", ErrInvalidAnalyticsQuery, q.Location)
+		}
+	}
+	return nil
+}
+
+// truncate returns the start of the bucket containing t, in the query's location.
+func (q *AnalyticsQuery) truncate(t time.Time) time.Time {
+	t = t.In(q.Location)
+	if q.Granularity == GranularityDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, q.Location)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, q.Location)
+}
+
+func (q *AnalyticsQuery) next(bucket time.Time) time.Time {
+	if q.Granularity == GranularityDay {
+		return bucket.AddDate(0, 0, 1)
+	}
+	return bucket.Add(time.Hour)
+}
+
+// buckets lists every bucket start in [From, To).
+func (q *AnalyticsQuery) buckets() []time.Time {
+	var out []time.Time
+	for b := q.From; b.Before(q.To); b = q.next(b) {
+		out = append(out, b)
+		if len(out) > maxHourBuckets+maxDayBuckets {
+			break // Oversized range, rejected by the caller
+		}
+	}
+	return out
+}
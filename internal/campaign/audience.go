@@ -0,0 +1,241 @@
+package campaign
+
+import (
+	"context"
+	"io"
+)
+
+// --- Campaign Whitelist (campaign_targets) ---
+
+// AddTargets whitelists users for a SEGMENT campaign (DB first, then Redis bitmap).
+func (s *Service) AddTargets(ctx context.Context, campaignID int64, userIDs []int64) error {
+	a, err := s.whitelist(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+	return s.addMembers(ctx, a, userIDs)
+}
+
+func (s *Service) RemoveTargets(ctx context.Context, campaignID int64, userIDs []int64) error {
+	a, err := s.whitelist(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+	return s.removeMembers(ctx, a, userIDs)
+}
+
+// ReplaceTargets sets the exact list of targeted users.
+func (s *Service) ReplaceTargets(ctx context.Context, campaignID int64, userIDs []int64) error {
+	a, err := s.whitelist(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+	return s.replaceMembers(ctx, a, userIDs)
+}
+
+func (s *Service) ListTargets(ctx context.Context, campaignID int64, afterUserID int64, limit int) ([]int64, error) {
+	a, err := s.whitelist(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.ListTargets(ctx, a, afterUserID, limit) // DB Only
+}
+
+// ImportTargets streams a CSV audience into a SEGMENT campaign's own whitelist.
+func (s *Service) ImportTargets(ctx context.Context, campaignID int64, r io.Reader, replace bool) (*ImportReport, error) {
+	a, err := s.whitelist(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.importAudience(ctx, a, r, replace)
+	if report != nil {
+		report.CampaignID = campaignID
+	}
+	return report, err
+}
+
+// whitelist verifies the campaign exists, is SEGMENT-targeted and uses its own list.
+func (s *Service) whitelist(ctx context.Context, campaignID int64) (Audience, error) {
+	c, err := s.store.GetByID(ctx, campaignID)
+	if err != nil {
+		return Audience{}, err
+	}
+	if c == nil {
+		return Audience{}, ErrNotFound
+	}
+	if c.TargetType != TargetTypeSegment {
+		return Audience{}, ErrNotSegment
+	}
+	if c.TargetSegment != "" {
+		return Audience{}, ErrUsesNamedSegment
+	}
+	return CampaignAudience(campaignID), nil
+}
+
+// --- Named Segments (segments, segment_members) ---
+
+func (s *Service) CreateSegment(ctx context.Context, seg *Segment) error {
+	if !ValidSegmentName(seg.Name) {
+		return ErrInvalidSegmentName
+	}
+	return s.store.CreateSegment(ctx, seg)
+}
+
+func (s *Service) ListSegments(ctx context.Context) ([]*Segment, error) {
+	return s.store.ListSegments(ctx) // DB Only
+}
+
+// DeleteSegment refuses to delete a segment still referenced by campaigns. The check gives the
+// common case a clear error; the campaigns.target_segment foreign key closes the race with a
+// campaign saved in between.
+func (s *Service) DeleteSegment(ctx context.Context, name string) error {
+	n, err := s.store.CountSegmentUsage(ctx, name)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrSegmentInUse
+	}
+	if err := s.store.DeleteSegment(ctx, name); err != nil {
+		return err
+	}
+	return s.repo.ReplaceTargetUsers(ctx, SegmentAudience(name), nil) // Drop the bitmap too
+}
+
+func (s *Service) AddSegmentMembers(ctx context.Context, name string, userIDs []int64) error {
+	a, err := s.segment(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.addMembers(ctx, a, userIDs)
+}
+
+func (s *Service) RemoveSegmentMembers(ctx context.Context, name string, userIDs []int64) error {
+	a, err := s.segment(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.removeMembers(ctx, a, userIDs)
+}
+
+func (s *Service) ReplaceSegmentMembers(ctx context.Context, name string, userIDs []int64) error {
+	a, err := s.segment(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.replaceMembers(ctx, a, userIDs)
+}
+
+func (s *Service) ListSegmentMembers(ctx context.Context, name string, afterUserID int64, limit int) ([]int64, error) {
+	a, err := s.segment(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.ListTargets(ctx, a, afterUserID, limit) // DB Only
+}
+
+func (s *Service) ImportSegmentMembers(ctx context.Context, name string, r io.Reader, replace bool) (*ImportReport, error) {
+	a, err := s.segment(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.importAudience(ctx, a, r, replace)
+	if report != nil {
+		report.Segment = name
+	}
+	return report, err
+}
+
+func (s *Service) segment(ctx context.Context, name string) (Audience, error) {
+	seg, err := s.store.GetSegment(ctx, name)
+	if err != nil {
+		return Audience{}, err
+	}
+	if seg == nil {
+		return Audience{}, ErrSegmentNotFound
+	}
+	return SegmentAudience(name), nil
+}
+
+// checkTargeting validates the rules of a RULES campaign and the named segment a campaign refers to, if any.
+func (s *Service) checkTargeting(ctx context.Context, c *Campaign) error {
+	if c.TargetType == TargetTypeRules {
+		if err := c.Rules.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.TargetSegment == "" {
+		return nil
+	}
+	if !ValidSegmentName(c.TargetSegment) {
+		return ErrInvalidSegmentName
+	}
+	_, err := s.segment(ctx, c.TargetSegment)
+	return err
+}
+
+// --- Shared membership writes (DB first, then Redis bitmap) ---
+
+func (s *Service) addMembers(ctx context.Context, a Audience, userIDs []int64) error {
+	if err := checkUserIDs(userIDs); err != nil {
+		return err
+	}
+	if err := s.store.AddTargets(ctx, a, userIDs); err != nil {
+		return err
+	}
+	return s.repo.AddTargetUsers(ctx, a, userIDs)
+}
+
+func (s *Service) removeMembers(ctx context.Context, a Audience, userIDs []int64) error {
+	if err := checkUserIDs(userIDs); err != nil {
+		return err
+	}
+	if err := s.store.RemoveTargets(ctx, a, userIDs); err != nil {
+		return err
+	}
+	return s.repo.RemoveTargetUsers(ctx, a, userIDs)
+}
+
+func (s *Service) replaceMembers(ctx context.Context, a Audience, userIDs []int64) error {
+	if err := checkUserIDs(userIDs); err != nil {
+		return err
+	}
+	if err := s.store.ReplaceTargets(ctx, a, userIDs); err != nil {
+		return err
+	}
+	return s.repo.ReplaceTargetUsers(ctx, a, userIDs)
+}
+
+// restoreAudience copies an audience from the DB into the cache in pages. Members are ORed
+// into the bitmap, so users added while it runs are kept.
+func (s *Service) restoreAudience(ctx context.Context, a Audience) error {
+	imp, err := s.repo.BeginTargetImport(ctx, a, false)
+	if err != nil {
+		return err
+	}
+	after := int64(-1)
+	for {
+		page, err := s.store.ListTargets(ctx, a, after, importBatchSize)
+		if err == nil {
+			err = imp.Add(ctx, page)
+		}
+		if err != nil {
+			imp.Abort(ctx)
+			return err
+		}
+		if len(page) < importBatchSize {
+			return imp.Commit(ctx)
+		}
+		after = page[len(page)-1]
+	}
+}
+
+// checkUserIDs ensures every ID fits a Redis bitmap offset.
+func checkUserIDs(userIDs []int64) error {
+	for _, uid := range userIDs {
+		if uid < 0 || uid > MaxUserID {
+			return ErrInvalidUserID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidBudget = errors.New("invalid budget")
+
+// Pacing decides how fast an impression budget may be spent.
+type Pacing string
+
+const (
+	PacingASAP Pacing = "asap" // As fast as traffic allows, until the budget is spent (default)
+	PacingEven Pacing = "even" // Evenly between StartTime and EndTime
+)
+
+// pacingLead is how far ahead of the even delivery line a campaign may run, so it serves from
+// its first minute and can absorb traffic peaks instead of trickling one impression at a time.
+const pacingLead = time.Hour
+
+// checkBudget validates the budget and pacing of a campaign being written.
+func checkBudget(c *Campaign) error {
+	if c.Budget < 0 {
+		return fmt.Errorf("%w: budget must not be negative", ErrInvalidBudget)
+	}
+	switch c.Pacing {
+	case "", PacingASAP:
+	case PacingEven:
+		if c.Budget == 0 {
+			return fmt.Errorf("%w: even pacing needs a budget", ErrInvalidBudget)
+		}
+		if !c.EndTime.After(c.StartTime) {
+			return fmt.Errorf("%w: even pacing needs an end_time after start_time", ErrInvalidBudget)
+		}
+	default:
+		return fmt.Errorf("%w: pacing must be asap or even", ErrInvalidBudget)
+	}
+	return nil
+}
+
+// DeliveryAllowance is how many impressions the campaign may have delivered, across all users,
+// by now. Without a budget it is unlimited (-1). Even pacing follows a straight line from 0 at
+// StartTime to Budget at EndTime, shifted pacingLead ahead.
+func (c *Campaign) DeliveryAllowance(now time.Time) int64 {
+	if c.Budget <= 0 {
+		return -1
+	}
+	if c.Pacing != PacingEven || !c.EndTime.After(c.StartTime) {
+		return c.Budget
+	}
+	share := float64(now.Sub(c.StartTime)+pacingLead) / float64(c.EndTime.Sub(c.StartTime))
+	if share >= 1 {
+		return c.Budget
+	}
+	if share <= 0 {
+		return 0 // Not started: a negative allowance would read as unlimited
+	}
+	return int64(share * float64(c.Budget))
+}
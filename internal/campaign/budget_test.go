@@ -0,0 +1,35 @@
+package campaign
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeliveryAllowance(t *testing.T) {
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(240 * time.Hour) // 10 impressions an hour for a budget of 2400
+	even := Campaign{Budget: 2400, Pacing: PacingEven, StartTime: start, EndTime: end}
+	tests := []struct {
+		name     string
+		campaign Campaign
+		at       time.Time
+		want     int64
+	}{
+		{"no budget", Campaign{StartTime: start, EndTime: end}, start, -1},
+		{"asap", Campaign{Budget: 2400, Pacing: PacingASAP, StartTime: start, EndTime: end}, start, 2400},
+		{"default pacing is asap", Campaign{Budget: 2400, StartTime: start, EndTime: end}, start, 2400},
+		{"even at start runs pacingLead ahead", even, start, 10},
+		{"even halfway", even, start.Add(120 * time.Hour), 1210},
+		{"even in the last hour", even, end.Add(-30 * time.Minute), 2400},
+		{"even after end", even, end.Add(time.Hour), 2400},
+		{"even before start", even, start.Add(-2 * time.Hour), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := FixedClock(tt.at).Now()
+			if got := tt.campaign.DeliveryAllowance(now); got != tt.want {
+				t.Errorf("DeliveryAllowance(%s) = %d, want %d", now, got, tt.want)
+			}
+		})
+	}
+}
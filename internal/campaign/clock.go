@@ -0,0 +1,19 @@
+package campaign
+
+import "time"
+
+// Clock is the Service's source of "now", so evaluation can be simulated at any instant
+// and is deterministic under test.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock reads the wall clock. Default of a new Service.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// FixedClock always returns the same instant.
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time { return time.Time(c) }
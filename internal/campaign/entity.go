@@ -2,28 +2,124 @@ package campaign
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 )
 
+var (
+	ErrNotFound      = errors.New("campaign not found")
+	ErrNotSegment    = errors.New("campaign target_type is not SEGMENT")
+	ErrInvalidUserID = errors.New("user_id must be between 0 and 4294967295") // Bitmap offset range
+	ErrEmptyImport   = errors.New("import contains no valid user_id")
+)
+
+// MaxUserID is the largest user ID a Redis bitmap can hold (SETBIT offset < 2^32).
+const MaxUserID = 1<<32 - 1
+
 type TargetType string
 
 const (
 	TargetTypeAll     TargetType = "ALL"
 	TargetTypeSegment TargetType = "SEGMENT"
+	TargetTypeRules   TargetType = "RULES" // Matched against the request context, see Rules
 )
 
 type Campaign struct {
-	ID            int64      `json:"id"`
-	Title         string     `json:"title"`
-	ImageURL      string     `json:"image_url"`
-	ActionURL     string     `json:"action_url"`
-	Priority      int        `json:"priority"`
-	StartTime     time.Time  `json:"start_time"`
-	EndTime       time.Time  `json:"end_time"`
-	MaxFrequency  int        `json:"max_frequency"`
-	TargetType    TargetType `json:"target_type"`
-	TargetSegment string     `json:"target_segment,omitempty"` // If TargetType == SEGMENT
-	IsActive      bool       `json:"is_active,omitempty"`      // For DB/Admin
+	ID            int64        `json:"id"`
+	Title         string       `json:"title"`
+	ImageURL      string       `json:"image_url"`
+	ActionURL     string       `json:"action_url"`
+	Priority      int          `json:"priority"`
+	Weight        int          `json:"weight,omitempty"` // Share within its priority tier under rotation (0 = 1)
+	StartTime     time.Time    `json:"start_time"`
+	EndTime       time.Time    `json:"end_time"`
+	Schedule      *Schedule    `json:"schedule,omitempty"` // Recurring slots within StartTime and EndTime (nil = always)
+	MaxFrequency  int          `json:"max_frequency"`      // Legacy lifetime cap, used when FrequencyCap.Lifetime is 0
+	FrequencyCap  FrequencyCap `json:"frequency_cap"`
+	ClickCap      FrequencyCap `json:"click_cap"`               // Stop showing after this many clicks
+	DismissHides  bool         `json:"dismiss_hides,omitempty"` // A DISMISS hides the campaign from that user for good
+	Budget        int64        `json:"budget,omitempty"`        // Total impressions across all users (0 = unlimited)
+	Pacing        Pacing       `json:"pacing,omitempty"`        // How fast Budget may be spent (default asap)
+	TargetType    TargetType   `json:"target_type"`
+	TargetSegment string       `json:"target_segment,omitempty"` // If TargetType == SEGMENT
+	Rules         Rules        `json:"rules,omitempty"`          // If TargetType == RULES
+	Placement     string       `json:"placement"`                // Surface the campaign competes on, see DefaultPlacement
+	Variants      Variants     `json:"variants,omitempty"`       // A/B creatives, one assigned per user
+	IsActive      bool         `json:"is_active,omitempty"`      // For DB/Admin
+}
+
+// Cap returns the effective frequency cap, falling back to MaxFrequency for the lifetime window.
+func (c *Campaign) Cap() FrequencyCap {
+	fc := c.FrequencyCap
+	if fc.Lifetime == 0 {
+		fc.Lifetime = c.MaxFrequency
+	}
+	return fc
+}
+
+// FrequencyCap limits how often a single user sees a campaign. Zero disables that window.
+// Day and week are calendar windows (ISO week, Monday start) in UTC, see WindowsAt.
+type FrequencyCap struct {
+	PerDay   int `json:"per_day,omitempty"`
+	PerWeek  int `json:"per_week,omitempty"`
+	Lifetime int `json:"lifetime,omitempty"`
+}
+
+// Reached reports whether any window of the cap is exhausted.
+func (f FrequencyCap) Reached(n ImpressionCounts) bool {
+	return (f.PerDay > 0 && n.Day >= f.PerDay) ||
+		(f.PerWeek > 0 && n.Week >= f.PerWeek) ||
+		(f.Lifetime > 0 && n.Lifetime >= f.Lifetime)
+}
+
+// ImpressionCounts is how often a user has seen a campaign within each cap window.
+type ImpressionCounts struct {
+	Day      int `json:"day"`
+	Week     int `json:"week"`
+	Lifetime int `json:"lifetime"`
+}
+
+// CapWindows identifies the day and week windows an instant falls into.
+type CapWindows struct {
+	Day       string // e.g. "20261016"
+	Week      string // e.g. "2026W42"
+	DayStart  time.Time
+	DayEnd    time.Time
+	WeekStart time.Time
+	WeekEnd   time.Time
+}
+
+// WindowsAt computes the cap windows for t in UTC, so every pod counts a user into the same
+// day and week whatever its local time zone.
+func WindowsAt(t time.Time) CapWindows {
+	t = t.UTC()
+	dayStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(dayStart.Weekday()) + 6) % 7 // Days since Monday
+	weekStart := dayStart.AddDate(0, 0, -offset)
+	year, week := t.ISOWeek()
+
+	return CapWindows{
+		Day:       dayStart.Format("20060102"),
+		Week:      fmt.Sprintf("%dW%02d", year, week),
+		DayStart:  dayStart,
+		DayEnd:    dayStart.AddDate(0, 0, 1),
+		WeekStart: weekStart,
+		WeekEnd:   weekStart.AddDate(0, 0, 7),
+	}
+}
+
+// EvaluationSnapshot is the per-user view of the active campaigns of one placement, used for in-memory evaluation.
+type EvaluationSnapshot struct {
+	ActiveIDs   []int64             // Sorted by priority, highest first
+	Campaigns   map[int64]*Campaign // Missing entry = metadata not found
+	Targeted    map[int64]bool      // User bit in the campaign's audience (segment or whitelist)
+	Impressions map[int64]ImpressionCounts
+	Clicks      map[int64]ImpressionCounts
+	Dismissed   map[int64]bool  // User has dismissed the campaign at least once
+	Delivered   map[int64]int64 // Impressions across all users, for budgets
+	Policy      *UserPolicy     // Nil = no user-level limits
+	Activity    UserActivity    // The user's popups across all campaigns, windowed like Impressions
 }
 
 // Repository (Redis - Hot Path)
@@ -31,12 +127,43 @@ type Repository interface {
 	GetActiveCampaignIDs(ctx context.Context) ([]int64, error)
 	GetCampaignsMetadata(ctx context.Context, ids []int64) (map[int64]*Campaign, error)
 	IsUserTargeted(ctx context.Context, campaignID int64, userID int64) (bool, error)
-	GetUserImpressions(ctx context.Context, userID int64, campaignIDs []int64) (map[int64]int, error)
-	IncrementImpression(ctx context.Context, userID int64, campaignID int64) error
-	
+	GetUserImpressions(ctx context.Context, userID int64, campaignIDs []int64, at time.Time) (map[int64]ImpressionCounts, error)
+	IncrementImpression(ctx context.Context, userID int64, c *Campaign, at time.Time) error // Also counts toward UserActivity
+	IncrementClick(ctx context.Context, userID int64, c *Campaign, at time.Time) error
+	MarkDismissed(ctx context.Context, userID int64, c *Campaign, at time.Time) error
+
+	// ClaimEvent remembers a client event ID for EventDedupeWindow; false means it was already seen.
+	ClaimEvent(ctx context.Context, userID int64, eventID string) (bool, error)
+	// ReleaseEvent forgets a claimed event ID that could not be recorded, so a retry counts it.
+	ReleaseEvent(ctx context.Context, userID int64, eventID string) error
+
+	// GetEvaluationSnapshot fetches everything GetPopup needs for a user on one placement in a single round-trip.
+	GetEvaluationSnapshot(ctx context.Context, userID int64, placement string, at time.Time) (*EvaluationSnapshot, error)
+
 	// Write methods for Syncing/Admin
 	SaveCampaign(ctx context.Context, c *Campaign) error
 	RemoveCampaign(ctx context.Context, id int64) error
+	SaveUserPolicy(ctx context.Context, p *UserPolicy) error
+	// RestoreDelivered raises a campaign's delivered impressions to at least count (from the DB),
+	// in case the hot-path counter was lost.
+	RestoreDelivered(ctx context.Context, c *Campaign, count int64, at time.Time) error
+
+	// Audience membership mirror (campaign:{id}:users, segment:{name}:users)
+	AddTargetUsers(ctx context.Context, a Audience, userIDs []int64) error
+	RemoveTargetUsers(ctx context.Context, a Audience, userIDs []int64) error
+	ReplaceTargetUsers(ctx context.Context, a Audience, userIDs []int64) error
+	BeginTargetImport(ctx context.Context, a Audience, replace bool) (TargetImport, error)
+
+	// GetCacheState lists everything the hot path currently holds, for reconciliation.
+	GetCacheState(ctx context.Context) (*CacheState, error)
+}
+
+// CacheState is the content of the hot-path cache, compared against the DB on sync.
+type CacheState struct {
+	Metadata   map[int64]*Campaign // campaign:{id}:meta
+	Active     map[int64]int       // campaigns:active member -> priority score
+	Placements map[int64][]string  // campaigns:active:{placement} ZSETs holding the campaign
+	Audiences  map[Audience]bool   // Target bitmaps present (an empty audience may have none)
 }
 
 // Store (PostgreSQL - Persistence)
@@ -46,4 +173,42 @@ type Store interface {
 	Delete(ctx context.Context, id int64) error
 	GetByID(ctx context.Context, id int64) (*Campaign, error)
 	List(ctx context.Context) ([]*Campaign, error)
+	ListActive(ctx context.Context) ([]*Campaign, error) // is_active and not yet ended, no limit
+
+	// Event log (campaign_impressions)
+	RecordEvents(ctx context.Context, events []Event) error
+	MetricsSeries(ctx context.Context, q AnalyticsQuery) ([]MetricsRow, error)          // Per campaign and bucket
+	MetricsTotals(ctx context.Context, q AnalyticsQuery) ([]MetricsRow, error)          // Per campaign over the range
+	MetricsVariantTotals(ctx context.Context, q AnalyticsQuery) ([]MetricsRow, error)   // Per campaign and A/B variant over the range
+	CountImpressions(ctx context.Context, campaignIDs []int64) (map[int64]int64, error) // VIEWs across all users, for budgets
+
+	// RollupEvents aggregates complete hours before upTo into the hourly rollups, and rebuilds
+	// hours that received late events. Safe to run concurrently from several pods.
+	RollupEvents(ctx context.Context, upTo time.Time) (*RollupReport, error)
+
+	// Segment membership (campaign_targets)
+	AddTargets(ctx context.Context, a Audience, userIDs []int64) error
+	RemoveTargets(ctx context.Context, a Audience, userIDs []int64) error
+	ReplaceTargets(ctx context.Context, a Audience, userIDs []int64) error
+	ListTargets(ctx context.Context, a Audience, afterUserID int64, limit int) ([]int64, error) // Keyset pagination, ascending
+	BeginTargetImport(ctx context.Context, a Audience, replace bool) (TargetImport, error)
+
+	// User-level limits across campaigns (user_policy, a single row)
+	GetUserPolicy(ctx context.Context) (*UserPolicy, error) // Nil when never set
+	SaveUserPolicy(ctx context.Context, p *UserPolicy) error
+
+	// Named segments (segments, segment_members)
+	CreateSegment(ctx context.Context, seg *Segment) error // ErrSegmentExists on duplicate name
+	GetSegment(ctx context.Context, name string) (*Segment, error)
+	ListSegments(ctx context.Context) ([]*Segment, error)
+	DeleteSegment(ctx context.Context, name string) error            // Members are deleted too
+	CountSegmentUsage(ctx context.Context, name string) (int, error) // Campaigns referencing it
+}
+
+// TargetImport streams a large audience in batches; nothing is visible to readers until Commit.
+// With replace the committed list becomes exactly the imported users, otherwise they are appended.
+type TargetImport interface {
+	Add(ctx context.Context, userIDs []int64) error
+	Commit(ctx context.Context) error
+	Abort(ctx context.Context) error
 }
@@ -0,0 +1,46 @@
+package campaign
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidAction  = errors.New("action must be VIEW, CLICK or DISMISS")
+	ErrInvalidEventID = errors.New("event_id must be at most 128 characters")
+	ErrEventRejected  = errors.New("event rejected by the event log") // Permanent: e.g. its campaign was deleted
+)
+
+// MaxEventIDLength bounds client event IDs (UUIDs, ULIDs, ...).
+const MaxEventIDLength = 128
+
+// EventDedupeWindow is how long a client event ID is remembered by the hot path.
+// A retry within the window is acknowledged but not counted again.
+const EventDedupeWindow = 24 * time.Hour
+
+// EventAction is what a user did with a popup (campaign_impressions.action).
+type EventAction string
+
+const (
+	ActionView    EventAction = "VIEW"
+	ActionClick   EventAction = "CLICK"
+	ActionDismiss EventAction = "DISMISS"
+)
+
+func (a EventAction) Valid() bool {
+	switch a {
+	case ActionView, ActionClick, ActionDismiss:
+		return true
+	}
+	return false
+}
+
+// Event is one row of the durable event log (campaign_impressions).
+type Event struct {
+	EventID    string      `json:"event_id,omitempty"` // Client-generated, for idempotent retries
+	CampaignID int64       `json:"campaign_id"`
+	VariantID  string      `json:"variant_id,omitempty"` // A/B variant the user was assigned when the event happened
+	UserID     int64       `json:"user_id"`
+	Action     EventAction `json:"action"`
+	At         time.Time   `json:"at"`
+}
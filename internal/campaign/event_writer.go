@@ -0,0 +1,240 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	eventFlushTimeout    = 10 * time.Second // Per flush attempt, and for the final flush on shutdown
+	eventMaxRetryBackoff = 30 * time.Second
+)
+
+// EventSink receives the events recorded by the Service.
+type EventSink interface {
+	Write(ctx context.Context, e Event) error
+}
+
+// storeSink writes every event synchronously, on the request path. Default of a new Service.
+type storeSink struct {
+	store Store
+}
+
+func (s storeSink) Write(ctx context.Context, e Event) error {
+	return s.store.RecordEvents(ctx, []Event{e})
+}
+
+// EventWriterStatus reports the health of the asynchronous event log writer.
+type EventWriterStatus struct {
+	Running             bool      `json:"running"`
+	Queued              int       `json:"queued"`  // In the channel, not yet picked up
+	Pending             int       `json:"pending"` // Picked up, waiting for a (re)try
+	Written             int64     `json:"written"`
+	Dropped             int64     `json:"dropped"`  // Buffer full, or retry backlog over its limit
+	Rejected            int64     `json:"rejected"` // Refused by the Store as invalid, never retried
+	LastFlush           time.Time `json:"last_flush"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+}
+
+// EventWriter persists events to the Store off the request path, in batches.
+// Write never blocks: when the buffer is full the event is dropped and counted.
+// While the DB is down, batches are kept and retried with backoff, up to maxPending events.
+// Events the Store rejects as invalid (ErrEventRejected) are dropped without holding up the rest.
+// Remaining events are flushed when Run's context is cancelled.
+type EventWriter struct {
+	store      Store
+	events     chan Event
+	batchSize  int
+	interval   time.Duration
+	maxPending int
+
+	mu     sync.Mutex
+	status EventWriterStatus
+}
+
+func NewEventWriter(store Store, bufferSize, batchSize int, interval time.Duration) *EventWriter {
+	return &EventWriter{
+		store:      store,
+		events:     make(chan Event, bufferSize),
+		batchSize:  batchSize,
+		interval:   interval,
+		maxPending: bufferSize * 10,
+	}
+}
+
+// Write enqueues an event for the next batch.
+func (w *EventWriter) Write(ctx context.Context, e Event) error {
+	select {
+	case w.events <- e:
+	default:
+		w.mu.Lock()
+		w.status.Dropped++
+		w.mu.Unlock()
+	}
+	return nil
+}
+
+// Run batches queued events until ctx is cancelled, then flushes what is left.
+func (w *EventWriter) Run(ctx context.Context) {
+	w.setRunning(true)
+	defer w.setRunning(false)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var pending []Event
+	var backoff time.Duration
+	var retryAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			w.shutdown(pending)
+			return
+		case e := <-w.events:
+			pending = append(pending, e)
+			if len(pending) < w.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+		}
+		if time.Now().Before(retryAt) {
+			pending = w.trim(pending)
+			continue // DB still failing, keep buffering
+		}
+
+		var err error
+		pending, err = w.flush(ctx, pending)
+		if err != nil {
+			backoff = min(max(2*backoff, w.interval), eventMaxRetryBackoff)
+			retryAt = time.Now().Add(backoff)
+			pending = w.trim(pending)
+			continue
+		}
+		backoff, retryAt = 0, time.Time{}
+	}
+}
+
+// Status returns a copy of the current status.
+func (w *EventWriter) Status() EventWriterStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	st := w.status
+	st.Queued = len(w.events)
+	return st
+}
+
+// flush writes pending in batches and returns what could not be written.
+func (w *EventWriter) flush(ctx context.Context, pending []Event) ([]Event, error) {
+	for len(pending) > 0 {
+		n := min(len(pending), w.batchSize)
+		done, rejected, err := w.writeValid(ctx, pending[:n])
+		pending = pending[done:]
+
+		w.mu.Lock()
+		w.status.Written += int64(done - rejected)
+		w.status.Rejected += int64(rejected)
+		w.status.Pending = len(pending)
+		if err != nil {
+			w.status.LastError = err.Error()
+			w.status.ConsecutiveFailures++
+			failures := w.status.ConsecutiveFailures
+			w.mu.Unlock()
+
+			log.Printf("event log flush failed (%d in a row, %d events pending): %v", failures, len(pending), err)
+			return pending, err
+		}
+		w.status.LastFlush = time.Now()
+		w.status.LastError = ""
+		w.status.ConsecutiveFailures = 0
+		w.mu.Unlock()
+	}
+	return nil, nil
+}
+
+// writeValid writes batch and, when the Store rejects it as invalid, splits it in halves until
+// only the events at fault are left, which are dropped. Events are handled in order: on any other
+// error the first done are written (or dropped) and the rest must be retried.
+func (w *EventWriter) writeValid(ctx context.Context, batch []Event) (done, rejected int, err error) {
+	err = w.writeBatch(ctx, batch)
+	switch {
+	case err == nil:
+		return len(batch), 0, nil
+	case !errors.Is(err, ErrEventRejected):
+		return 0, 0, err
+	case len(batch) == 1:
+		log.Printf("event log: dropped %s of campaign %d by user %d: %v", batch[0].Action, batch[0].CampaignID, batch[0].UserID, err)
+		return 1, 1, nil
+	}
+
+	half := len(batch) / 2
+	done, rejected, err = w.writeValid(ctx, batch[:half])
+	if err != nil {
+		return done, rejected, err
+	}
+	d, r, err := w.writeValid(ctx, batch[half:])
+	return done + d, rejected + r, err
+}
+
+// writeBatch bounds each attempt and turns a panic into an error so the writer keeps running.
+func (w *EventWriter) writeBatch(ctx context.Context, batch []Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("event flush panicked: %v", r)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, eventFlushTimeout)
+	defer cancel()
+	return w.store.RecordEvents(ctx, batch)
+}
+
+// trim drops the oldest events once the retry backlog is over its limit.
+func (w *EventWriter) trim(pending []Event) []Event {
+	over := len(pending) - w.maxPending
+	if over <= 0 {
+		return pending
+	}
+	w.mu.Lock()
+	w.status.Dropped += int64(over)
+	w.status.Pending = w.maxPending
+	w.mu.Unlock()
+	return pending[over:]
+}
+
+// shutdown drains the channel and makes a last attempt to write everything.
+func (w *EventWriter) shutdown(pending []Event) {
+drain:
+	for {
+		select {
+		case e := <-w.events:
+			pending = append(pending, e)
+		default:
+			break drain
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), eventFlushTimeout)
+	defer cancel()
+	if rest, err := w.flush(ctx, pending); err != nil {
+		log.Printf("event log: %d events lost on shutdown: %v", len(rest), err)
+		return
+	}
+	log.Printf("event log: flushed %d events on shutdown", len(pending))
+}
+
+func (w *EventWriter) setRunning(running bool) {
+	w.mu.Lock()
+	w.status.Running = running
+	w.mu.Unlock()
+}
@@ -0,0 +1,120 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// eventStore records events like the Postgres store: a batch is written whole or not at all.
+type eventStore struct {
+	Store
+	rejectUser int64 // Batches holding an event of this user are rejected as invalid
+	down       bool  // Every batch fails with a transient error
+	written    []int64
+}
+
+func (s *eventStore) RecordEvents(_ context.Context, events []Event) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	for _, e := range events {
+		if e.UserID == s.rejectUser {
+			return fmt.Errorf("%w: campaign %d does not exist", ErrEventRejected, e.CampaignID)
+		}
+	}
+	for _, e := range events {
+		s.written = append(s.written, e.UserID)
+	}
+	return nil
+}
+
+func eventsOf(users ...int64) []Event {
+	events := make([]Event, len(users))
+	for i, u := range users {
+		events[i] = Event{CampaignID: 1, UserID: u, Action: ActionView}
+	}
+	return events
+}
+
+func TestEventWriterFlush(t *testing.T) {
+	tests := []struct {
+		name         string
+		store        *eventStore
+		pending      []Event
+		wantWritten  []int64
+		wantPending  int
+		wantErr      bool
+		wantRejected int64
+	}{
+		{
+			name:        "all written",
+			store:       &eventStore{},
+			pending:     eventsOf(1, 2, 3, 4, 5),
+			wantWritten: []int64{1, 2, 3, 4, 5},
+		},
+		{
+			name:         "rejected event dropped, the rest written in order",
+			store:        &eventStore{rejectUser: 4},
+			pending:      eventsOf(1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+			wantWritten:  []int64{1, 2, 3, 5, 6, 7, 8, 9, 10},
+			wantRejected: 1,
+		},
+		{
+			name:         "every event of a rejected user dropped",
+			store:        &eventStore{rejectUser: 2},
+			pending:      eventsOf(2, 1, 2, 2),
+			wantWritten:  []int64{1},
+			wantRejected: 3,
+		},
+		{
+			name:        "DB down, everything kept for a retry",
+			store:       &eventStore{down: true},
+			pending:     eventsOf(1, 2, 3),
+			wantPending: 3,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewEventWriter(tt.store, 10, 4, time.Second)
+
+			rest, err := w.flush(context.Background(), tt.pending)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(rest) != tt.wantPending {
+				t.Errorf("%d events pending, want %d", len(rest), tt.wantPending)
+			}
+			if !reflect.DeepEqual(tt.store.written, tt.wantWritten) {
+				t.Errorf("written users = %v, want %v", tt.store.written, tt.wantWritten)
+			}
+			st := w.Status()
+			if st.Written != int64(len(tt.wantWritten)) || st.Rejected != tt.wantRejected {
+				t.Errorf("status written %d, rejected %d, want %d and %d", st.Written, st.Rejected, len(tt.wantWritten), tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestEventWriterRunDropsRejectedEvent(t *testing.T) {
+	store := &eventStore{rejectUser: 3}
+	w := NewEventWriter(store, 10, 4, time.Hour)
+	for _, e := range eventsOf(1, 2, 3, 4, 5) {
+		w.Write(context.Background(), e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // Run flushes what is queued on shutdown
+	w.Run(ctx)
+
+	if want := []int64{1, 2, 4, 5}; !reflect.DeepEqual(store.written, want) {
+		t.Errorf("written users = %v, want %v", store.written, want)
+	}
+	if st := w.Status(); st.Rejected != 1 || st.Pending != 0 || st.ConsecutiveFailures != 0 {
+		t.Errorf("status = %+v, want 1 rejected and nothing pending", st)
+	}
+}
@@ -0,0 +1,110 @@
+package campaign
+
+import (
+	"context"
+	"time"
+)
+
+// RejectReason says why a campaign was not served to a user.
+type RejectReason string
+
+const (
+	RejectMissingMetadata RejectReason = "missing_metadata"      // Active in the ZSET but no campaign:{id}:meta
+	RejectNotStarted      RejectReason = "not_started"           // Before start_time
+	RejectEnded           RejectReason = "ended"                 // After end_time
+	RejectOffSchedule     RejectReason = "off_schedule"          // Between start_time and end_time, outside the recurring schedule
+	RejectNotTargeted     RejectReason = "not_targeted"          // SEGMENT campaign, user not in the audience
+	RejectRulesNotMatched RejectReason = "rules_not_matched"     // RULES campaign, request context does not match
+	RejectCapReached      RejectReason = "frequency_cap_reached" // Impressions per day, week or lifetime
+	RejectClickCapReached RejectReason = "click_cap_reached"
+	RejectDismissed       RejectReason = "dismissed"
+	RejectBudgetSpent     RejectReason = "budget_exhausted" // Impression budget delivered
+	RejectAheadOfPace     RejectReason = "ahead_of_pace"    // Even pacing: delivered more than the schedule allows yet
+	RejectOutranked       RejectReason = "outranked"        // Eligible, but a higher priority campaign won
+)
+
+// Candidate is one active campaign as evaluated for a user.
+type Candidate struct {
+	CampaignID  int64            `json:"campaign_id"`
+	Title       string           `json:"title,omitempty"`
+	Priority    int              `json:"priority"`
+	Weight      int              `json:"weight,omitempty"`
+	VariantID   string           `json:"variant_id,omitempty"` // Variant the user is assigned to
+	Won         bool             `json:"won"`
+	Reason      RejectReason     `json:"reason,omitempty"` // Empty for the winner
+	Targeted    bool             `json:"targeted"`
+	Impressions ImpressionCounts `json:"impressions"`
+	Clicks      ImpressionCounts `json:"clicks"`
+	Dismissed   bool             `json:"dismissed"`
+	Delivered   int64            `json:"delivered,omitempty"` // Campaign-wide impressions, when it has a budget
+}
+
+// Decision explains a GetPopup evaluation: every active campaign of the placement in evaluation order
+// (priority, then rotation within a tier).
+type Decision struct {
+	UserID     int64          `json:"user_id"`
+	Placement  string         `json:"placement"`
+	At         time.Time      `json:"at"`
+	Simulated  bool           `json:"simulated,omitempty"` // Evaluated at a requested instant, not now
+	Context    RequestContext `json:"context"`
+	Policy     *UserPolicy    `json:"policy,omitempty"`
+	Activity   UserActivity   `json:"activity"`
+	Blocked    RejectReason   `json:"blocked,omitempty"`   // User policy blocks every campaign
+	WinnerID   int64          `json:"winner_id,omitempty"` // 0 = nothing would be shown
+	Candidates []Candidate    `json:"candidates"`
+}
+
+// ExplainPopup runs the GetPopup evaluation for a user on a placement and reports every candidate with the
+// reason it was rejected. Nothing is recorded and no serve token is issued.
+// A non-zero at simulates the evaluation at that instant instead of now: schedules and the
+// day/week cap windows are taken at that time, against the user's current counters.
+func (s *Service) ExplainPopup(ctx context.Context, userID int64, placement string, rc RequestContext, at time.Time) (*Decision, error) {
+	placement, err := resolvePlacement(placement)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	if !at.IsZero() {
+		now = at.In(now.Location()) // Reported in the clock's zone; cap windows are UTC either way
+	}
+
+	snap, err := s.repo.GetEvaluationSnapshot(ctx, userID, placement, now)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Decision{
+		UserID: userID, Placement: placement, At: now, Simulated: !at.IsZero(), Context: rc,
+		Policy: snap.Policy, Activity: snap.Activity, Candidates: make([]Candidate, 0, len(snap.ActiveIDs)),
+	}
+	_, d.Blocked = snap.Policy.remaining(snap.Activity, now)
+	for _, id := range rotate(s.rotation, snap.ActiveIDs, snap.Campaigns, userID) {
+		c := Candidate{
+			CampaignID:  id,
+			Targeted:    snap.Targeted[id],
+			Impressions: snap.Impressions[id],
+			Clicks:      snap.Clicks[id],
+			Dismissed:   snap.Dismissed[id],
+			Delivered:   snap.Delivered[id],
+			Reason:      snap.reject(id, rc, now),
+		}
+		if camp, ok := snap.Campaigns[id]; ok {
+			c.Title, c.Priority, c.Weight = camp.Title, camp.Priority, camp.Weight
+			if v := camp.VariantFor(userID); v != nil {
+				c.VariantID = v.ID
+			}
+		}
+		switch {
+		case c.Reason != "":
+		case d.Blocked != "":
+			c.Reason = d.Blocked
+		case d.WinnerID != 0:
+			c.Reason = RejectOutranked
+		default:
+			c.Won = true
+			d.WinnerID = id
+		}
+		d.Candidates = append(d.Candidates, c)
+	}
+	return d, nil
+}
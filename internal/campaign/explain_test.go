@@ -0,0 +1,149 @@
+package campaign
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clockRepo serves snapshots like the hot path: the user's impressions are counted per cap window
+// of the instant asked for, so a simulated instant sees the day and week it falls into.
+type clockRepo struct {
+	Repository
+	campaigns []*Campaign           // Active, highest priority first
+	views     map[int64][]time.Time // The user's impressions per campaign
+	delivered map[int64]int64
+	at        time.Time // Instant of the last snapshot
+}
+
+func (r *clockRepo) GetEvaluationSnapshot(_ context.Context, _ int64, _ string, at time.Time) (*EvaluationSnapshot, error) {
+	r.at = at
+	w := WindowsAt(at)
+	snap := &EvaluationSnapshot{
+		Campaigns:   map[int64]*Campaign{},
+		Impressions: map[int64]ImpressionCounts{},
+		Delivered:   r.delivered,
+	}
+	for _, c := range r.campaigns {
+		snap.ActiveIDs = append(snap.ActiveIDs, c.ID)
+		snap.Campaigns[c.ID] = c
+		var n ImpressionCounts
+		for _, v := range r.views[c.ID] {
+			vw := WindowsAt(v)
+			if vw.Day == w.Day {
+				n.Day++
+			}
+			if vw.Week == w.Week {
+				n.Week++
+			}
+			n.Lifetime++
+		}
+		snap.Impressions[c.ID] = n
+	}
+	return snap, nil
+}
+
+func TestServiceDecisionsAtInstant(t *testing.T) {
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC) // A Monday
+	end := start.AddDate(0, 0, 10)
+	lunch := &Campaign{ // 11:00 to 14:00 in Berlin, 09:00 to 12:00 UTC in June
+		ID: 1, Title: "lunch", Priority: 30, StartTime: start, EndTime: end,
+		Schedule: &Schedule{Timezone: "Europe/Berlin", Hours: []HourRange{{"11:00", "14:00"}}},
+	}
+	daily := &Campaign{ID: 2, Title: "daily", Priority: 20, StartTime: start, EndTime: end, FrequencyCap: FrequencyCap{PerDay: 1}}
+	paced := &Campaign{ // 400 delivered: on pace from 2026-06-04 23:00 UTC, the lead included
+		ID: 3, Title: "paced", Priority: 10, StartTime: start, EndTime: end, Budget: 1000, Pacing: PacingEven,
+	}
+	repo := &clockRepo{
+		campaigns: []*Campaign{lunch, daily, paced},
+		views:     map[int64][]time.Time{daily.ID: {time.Date(2026, 6, 3, 23, 30, 0, 0, time.UTC)}}, // 01:30 the next day in Berlin
+		delivered: map[int64]int64{paced.ID: 400},
+	}
+
+	tests := []struct {
+		name       string
+		clock      time.Time
+		at         time.Time // Simulated instant (zero = the clock's now)
+		wantReason map[int64]RejectReason
+		wantWinner int64
+	}{
+		{
+			name:       "before the start",
+			clock:      time.Date(2026, 5, 31, 23, 0, 0, 0, time.UTC),
+			wantReason: map[int64]RejectReason{1: RejectNotStarted, 2: RejectNotStarted, 3: RejectNotStarted},
+		},
+		{
+			name:       "same UTC day as the view, after midnight in Berlin",
+			clock:      time.Date(2026, 6, 3, 23, 45, 0, 0, time.UTC),
+			wantReason: map[int64]RejectReason{1: RejectOffSchedule, 2: RejectCapReached, 3: RejectAheadOfPace},
+		},
+		{
+			name:       "next UTC day",
+			clock:      time.Date(2026, 6, 3, 23, 45, 0, 0, time.UTC),
+			at:         time.Date(2026, 6, 4, 0, 15, 0, 0, time.UTC),
+			wantReason: map[int64]RejectReason{1: RejectOffSchedule, 2: "", 3: RejectAheadOfPace},
+			wantWinner: 2,
+		},
+		{
+			name:       "lunch time in Berlin, back on pace",
+			clock:      time.Date(2026, 6, 5, 10, 0, 0, 0, time.UTC),
+			wantReason: map[int64]RejectReason{1: "", 2: RejectOutranked, 3: RejectOutranked},
+			wantWinner: 1,
+		},
+		{
+			name:       "simulated in another zone",
+			clock:      time.Date(2026, 6, 3, 23, 45, 0, 0, time.UTC),
+			at:         time.Date(2026, 6, 5, 12, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)),
+			wantReason: map[int64]RejectReason{1: "", 2: RejectOutranked, 3: RejectOutranked},
+			wantWinner: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(repo, nil)
+			s.SetClock(FixedClock(tt.clock))
+			now := tt.clock
+			if !tt.at.IsZero() {
+				now = tt.at
+			}
+
+			d, err := s.ExplainPopup(context.Background(), 42, "", RequestContext{}, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !repo.at.Equal(now) || !d.At.Equal(now) || d.Simulated != !tt.at.IsZero() {
+				t.Errorf("evaluated at %s (snapshot %s, simulated %v), want %s", d.At, repo.at, d.Simulated, now)
+			}
+			got := map[int64]RejectReason{}
+			for _, c := range d.Candidates {
+				got[c.CampaignID] = c.Reason
+			}
+			if !reflect.DeepEqual(got, tt.wantReason) || d.WinnerID != tt.wantWinner {
+				t.Errorf("reasons = %v, winner %d, want %v and %d", got, d.WinnerID, tt.wantReason, tt.wantWinner)
+			}
+
+			// GetPopups serves at the clock's now what ExplainPopup reports as eligible there
+			if !tt.at.IsZero() {
+				s.SetClock(FixedClock(tt.at))
+			}
+			popups, err := s.GetPopups(context.Background(), 42, "", RequestContext{}, MaxPopups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var served []int64
+			for _, p := range popups {
+				served = append(served, p.Campaign.ID)
+			}
+			var want []int64
+			for _, c := range repo.campaigns {
+				if r := tt.wantReason[c.ID]; r == "" || r == RejectOutranked {
+					want = append(want, c.ID)
+				}
+			}
+			if !reflect.DeepEqual(served, want) {
+				t.Errorf("GetPopups served %v, want %v", served, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package campaign
+
+import (
+	"errors"
+	"regexp"
+)
+
+var ErrInvalidPlacement = errors.New("placement must match ^[a-z0-9_-]{1,32}$")
+
+// DefaultPlacement is the surface of campaigns that do not declare one, and of popup requests without a placement.
+const DefaultPlacement = "default"
+
+// placementPattern keeps names safe to embed in Redis keys (campaigns:active:{placement}).
+var placementPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ValidPlacement reports whether name is a well-formed placement name.
+func ValidPlacement(name string) bool {
+	return placementPattern.MatchString(name)
+}
+
+// resolvePlacement maps an empty placement to DefaultPlacement and validates the rest.
+func resolvePlacement(name string) (string, error) {
+	if name == "" {
+		return DefaultPlacement, nil
+	}
+	if !ValidPlacement(name) {
+		return "", ErrInvalidPlacement
+	}
+	return name, nil
+}
+
+// PlacementName returns the campaign's placement, DefaultPlacement for metadata cached before placements existed.
+func (c *Campaign) PlacementName() string {
+	if c.Placement == "" {
+		return DefaultPlacement
+	}
+	return c.Placement
+}
+
+// checkPlacement defaults and validates the placement of a campaign being written.
+func checkPlacement(c *Campaign) error {
+	p, err := resolvePlacement(c.Placement)
+	if err != nil {
+		return err
+	}
+	c.Placement = p
+	return nil
+}
@@ -0,0 +1,96 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidPolicy = errors.New("invalid user policy")
+
+// Reasons a user policy blocks every campaign of a request.
+const (
+	RejectUserCooldown    RejectReason = "user_cooldown"     // Saw a popup less than cooldown_seconds ago
+	RejectUserDailyLimit  RejectReason = "user_daily_limit"  // Popups seen today, across all campaigns
+	RejectUserWeeklyLimit RejectReason = "user_weekly_limit" // Popups seen this week, across all campaigns
+)
+
+// UserPolicy limits how often one user sees popups across all campaigns, on top of each
+// campaign's own caps. Zero disables a limit. Day and week are the cap windows of FrequencyCap.
+type UserPolicy struct {
+	CooldownSeconds int       `json:"cooldown_seconds,omitempty"` // Minimum gap after a popup was seen
+	PerDay          int       `json:"per_day,omitempty"`
+	PerWeek         int       `json:"per_week,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at,omitempty"`
+}
+
+// UserActivity is what a user has seen across all campaigns (VIEW events).
+type UserActivity struct {
+	LastSeen time.Time `json:"last_seen,omitempty"` // Zero = nothing seen recently
+	Day      int       `json:"day"`
+	Week     int       `json:"week"`
+}
+
+// UserActivityTTL is how long the hot path needs to remember a user's activity: the weekly
+// window plus a day. Longer cooldowns are not supported.
+const UserActivityTTL = 8 * 24 * time.Hour
+
+func (p *UserPolicy) Validate() error {
+	if p.CooldownSeconds < 0 || p.PerDay < 0 || p.PerWeek < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidPolicy)
+	}
+	if time.Duration(p.CooldownSeconds)*time.Second > UserActivityTTL {
+		return fmt.Errorf("%w: cooldown must not exceed %s", ErrInvalidPolicy, UserActivityTTL)
+	}
+	return nil
+}
+
+// remaining returns how many more popups the user may see at now, with the reason when none
+// (-1 = unlimited). A nil policy allows everything. With a cooldown only one popup is served at
+// a time: the cooldown starts when the first one is seen.
+func (p *UserPolicy) remaining(a UserActivity, now time.Time) (int, RejectReason) {
+	if p == nil {
+		return -1, ""
+	}
+	if p.CooldownSeconds > 0 && !a.LastSeen.IsZero() && now.Before(a.LastSeen.Add(time.Duration(p.CooldownSeconds)*time.Second)) {
+		return 0, RejectUserCooldown
+	}
+	n := -1
+	if p.PerDay > 0 {
+		if a.Day >= p.PerDay {
+			return 0, RejectUserDailyLimit
+		}
+		n = p.PerDay - a.Day
+	}
+	if p.PerWeek > 0 {
+		if a.Week >= p.PerWeek {
+			return 0, RejectUserWeeklyLimit
+		}
+		if n < 0 || p.PerWeek-a.Week < n {
+			n = p.PerWeek - a.Week
+		}
+	}
+	if p.CooldownSeconds > 0 {
+		n = 1
+	}
+	return n, ""
+}
+
+// GetUserPolicy returns the policy from the DB; nil when none was ever set.
+func (s *Service) GetUserPolicy(ctx context.Context) (*UserPolicy, error) {
+	return s.store.GetUserPolicy(ctx)
+}
+
+// SetUserPolicy replaces the user policy in the DB and the hot path.
+func (s *Service) SetUserPolicy(ctx context.Context, p *UserPolicy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	// 1. Save to DB (stamps UpdatedAt)
+	if err := s.store.SaveUserPolicy(ctx, p); err != nil {
+		return err
+	}
+	// 2. Sync to Redis
+	return s.repo.SaveUserPolicy(ctx, p)
+}
@@ -0,0 +1,68 @@
+package campaign
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RollupReport describes one rollup run.
+type RollupReport struct {
+	Watermark time.Time `json:"watermark"`  // Hours before this are served from the rollups
+	NewHours  int       `json:"new_hours"`  // Hours rolled up for the first time
+	LateHours int       `json:"late_hours"` // Already rolled-up hours rebuilt because late events arrived
+}
+
+// RollupStatus reports the health of the background event rollup.
+type RollupStatus struct {
+	Running             bool          `json:"running"`
+	Interval            string        `json:"interval"`
+	LastAttempt         time.Time     `json:"last_attempt"`
+	LastSuccess         time.Time     `json:"last_success"`
+	LagSeconds          float64       `json:"lag_seconds"` // Time since the watermark, i.e. size of the raw tail
+	LastError           string        `json:"last_error,omitempty"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	LastReport          *RollupReport `json:"last_report,omitempty"`
+}
+
+// RollupWorker periodically aggregates campaign_impressions into hourly rollups.
+// Each run is idempotent (whole hours are rebuilt), so a failed run is simply retried on the next tick.
+type RollupWorker struct {
+	worker periodicWorker[*RollupReport]
+}
+
+func NewRollupWorker(store Store, interval time.Duration) *RollupWorker {
+	return &RollupWorker{worker: periodicWorker[*RollupReport]{
+		name:     "event rollup",
+		interval: interval,
+		job:      store.RollupEvents,
+		done: func(report *RollupReport, _ time.Duration) {
+			if report.NewHours > 0 || report.LateHours > 0 {
+				log.Printf("event rollup: %d new, %d late hours, watermark %s", report.NewHours, report.LateHours, report.Watermark.Format(time.RFC3339))
+			}
+		},
+	}}
+}
+
+// Run rolls up immediately, then every interval, until ctx is cancelled.
+func (w *RollupWorker) Run(ctx context.Context) {
+	w.worker.Run(ctx)
+}
+
+// Status returns a copy of the current status with an up-to-date lag.
+func (w *RollupWorker) Status() RollupStatus {
+	st := w.worker.snapshot()
+	status := RollupStatus{
+		Running:             st.running,
+		Interval:            w.worker.interval.String(),
+		LastAttempt:         st.lastAttempt,
+		LastSuccess:         st.lastSuccess,
+		LastError:           st.lastError,
+		ConsecutiveFailures: st.consecutiveFailures,
+		LastReport:          st.lastReport,
+	}
+	if st.lastReport != nil && !st.lastReport.Watermark.IsZero() {
+		status.LagSeconds = time.Since(st.lastReport.Watermark).Seconds()
+	}
+	return status
+}
@@ -0,0 +1,92 @@
+package campaign
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+var ErrInvalidWeight = errors.New("weight must not be negative")
+
+// Rotation decides the order of campaigns that share a priority tier.
+type Rotation string
+
+const (
+	RotationOff      Rotation = "off"      // Backend order: ties go to the highest ID, compared as a string by Redis
+	RotationWeighted Rotation = "weighted" // Drawn on every request, in proportion to Weight
+	RotationSticky   Rotation = "sticky"   // Drawn in proportion to Weight, but once per user
+)
+
+func (r Rotation) Valid() bool {
+	switch r {
+	case RotationOff, RotationWeighted, RotationSticky:
+		return true
+	}
+	return false
+}
+
+// RotationWeight is the campaign's share within its priority tier; 0 counts as 1, so campaigns
+// without a configured weight rotate evenly.
+func (c *Campaign) RotationWeight() int {
+	if c.Weight <= 0 {
+		return 1
+	}
+	return c.Weight
+}
+
+// rotate reorders every priority tier of ids (sorted by priority, highest first) by weighted
+// sampling without replacement: each campaign draws u in (0,1] and the tier is sorted by
+// u^(1/weight), highest first. Dropping ineligible campaigns from that order leaves the rest
+// in proportion to their weights, so a capped campaign does not skew the others.
+// Sticky rotation derives u from the user and campaign IDs, so a user's order is stable and
+// does not reshuffle when other campaigns join or leave the tier. ids is not modified.
+func rotate(mode Rotation, ids []int64, campaigns map[int64]*Campaign, userID int64) []int64 {
+	if mode != RotationWeighted && mode != RotationSticky {
+		return ids
+	}
+
+	out := make([]int64, len(ids))
+	copy(out, ids)
+	keys := make(map[int64]float64, len(ids))
+	for i := 0; i < len(out); {
+		c, ok := campaigns[out[i]]
+		if !ok {
+			i++ // No metadata, no tier: rejected anyway
+			continue
+		}
+		j := i + 1
+		for j < len(out) && campaigns[out[j]] != nil && campaigns[out[j]].Priority == c.Priority {
+			j++
+		}
+		if j-i > 1 {
+			tier := out[i:j]
+			for _, id := range tier {
+				u := 1 - rand.Float64()
+				if mode == RotationSticky {
+					u = stickyDraw(userID, id)
+				}
+				keys[id] = math.Log(u) / float64(campaigns[id].RotationWeight()) // log(u^(1/w)), same order
+			}
+			sort.SliceStable(tier, func(a, b int) bool { return keys[tier[a]] > keys[tier[b]] })
+		}
+		i = j
+	}
+	return out
+}
+
+// stickyDraw maps a user and campaign to a fixed pseudo-random number in (0,1].
+func stickyDraw(userID, campaignID int64) float64 {
+	x := mix64(uint64(userID) ^ mix64(uint64(campaignID)))
+	return float64(x>>11+1) / (1 << 53)
+}
+
+// mix64 is the SplitMix64 finalizer: a cheap, well-distributed 64-bit hash.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
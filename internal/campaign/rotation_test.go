@@ -0,0 +1,90 @@
+package campaign
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestRotateKeepsTiers(t *testing.T) {
+	campaigns := map[int64]*Campaign{
+		1: {ID: 1, Priority: 10, Weight: 1},
+		2: {ID: 2, Priority: 10, Weight: 5},
+		3: {ID: 3, Priority: 5},
+		4: {ID: 4, Priority: 5},
+		5: {ID: 5, Priority: 1},
+	}
+	ids := []int64{2, 1, 6, 4, 3, 5} // 6 has no metadata
+	tests := []struct {
+		name  string
+		mode  Rotation
+		tiers [][]int64 // Expected tiers in order; ids within a tier in any order
+	}{
+		{"off", RotationOff, [][]int64{{2}, {1}, {6}, {4}, {3}, {5}}},
+		{"weighted", RotationWeighted, [][]int64{{1, 2}, {6}, {3, 4}, {5}}},
+		{"sticky", RotationSticky, [][]int64{{1, 2}, {6}, {3, 4}, {5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for user := int64(1); user <= 100; user++ {
+				got := rotate(tt.mode, ids, campaigns, user)
+				rest := got
+				for _, tier := range tt.tiers {
+					head := slices.Clone(rest[:len(tier)])
+					slices.Sort(head)
+					if !slices.Equal(head, tier) {
+						t.Fatalf("rotate(%s, user %d) = %v, want tiers %v", tt.mode, user, got, tt.tiers)
+					}
+					rest = rest[len(tier):]
+				}
+			}
+			if !slices.Equal(ids, []int64{2, 1, 6, 4, 3, 5}) {
+				t.Fatalf("rotate modified ids: %v", ids)
+			}
+		})
+	}
+}
+
+func TestRotateShares(t *testing.T) {
+	campaigns := map[int64]*Campaign{
+		1: {ID: 1, Priority: 10, Weight: 1},
+		2: {ID: 2, Priority: 10, Weight: 3},
+		3: {ID: 3, Priority: 10}, // Weight 0 counts as 1
+	}
+	ids := []int64{3, 2, 1}
+	want := map[int64]float64{1: 0.2, 2: 0.6, 3: 0.2}
+
+	for _, mode := range []Rotation{RotationWeighted, RotationSticky} {
+		t.Run(string(mode), func(t *testing.T) {
+			const draws = 20000
+			first := map[int64]int{}
+			for user := int64(1); user <= draws; user++ {
+				first[rotate(mode, ids, campaigns, user)[0]]++
+			}
+			for id, share := range want {
+				if got := float64(first[id]) / draws; math.Abs(got-share) > 0.02 {
+					t.Errorf("campaign %d came first for %.3f of draws, want %.2f", id, got, share)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateStickyIsStable(t *testing.T) {
+	campaigns := map[int64]*Campaign{
+		1: {ID: 1, Priority: 10, Weight: 2},
+		2: {ID: 2, Priority: 10, Weight: 2},
+		3: {ID: 3, Priority: 10, Weight: 2},
+	}
+	for user := int64(1); user <= 100; user++ {
+		all := rotate(RotationSticky, []int64{3, 2, 1}, campaigns, user)
+		if again := rotate(RotationSticky, []int64{3, 2, 1}, campaigns, user); !slices.Equal(all, again) {
+			t.Fatalf("user %d: order changed from %v to %v", user, all, again)
+		}
+		// Another campaign leaving the tier does not reshuffle the rest
+		without := rotate(RotationSticky, []int64{2, 1}, campaigns, user)
+		if want := slices.DeleteFunc(slices.Clone(all), func(id int64) bool { return id == 3 }); !slices.Equal(without, want) {
+			t.Fatalf("user %d: order without campaign 3 is %v, want %v", user, without, want)
+		}
+	}
+}
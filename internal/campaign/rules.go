@@ -0,0 +1,192 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidRules = errors.New("invalid targeting rules")
+
+// Attributes a rule can test, all taken from the popup request.
+const (
+	AttrPlatform   = "platform"    // e.g. "ios", "android", "web"
+	AttrAppVersion = "app_version" // Dotted numeric, e.g. "5.12.1"
+	AttrCountry    = "country"     // ISO 3166-1 alpha-2, e.g. "US"
+	AttrLanguage   = "language"    // BCP 47 tag, e.g. "en" or "en-US"
+	AttrTier       = "tier"        // Account tier, e.g. "free", "premium"
+)
+
+// Rule operators. gte / lt compare app versions numerically, segment by segment.
+const (
+	OpIn    = "in"
+	OpNotIn = "not_in"
+	OpGTE   = "gte"
+	OpLT    = "lt"
+)
+
+// RequestContext describes the client asking for a popup. Empty fields are unknown.
+type RequestContext struct {
+	Platform   string `json:"platform,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
+	Country    string `json:"country,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Tier       string `json:"tier,omitempty"`
+}
+
+func (rc RequestContext) attr(name string) string {
+	switch name {
+	case AttrPlatform:
+		return rc.Platform
+	case AttrAppVersion:
+		return rc.AppVersion
+	case AttrCountry:
+		return rc.Country
+	case AttrLanguage:
+		return rc.Language
+	case AttrTier:
+		return rc.Tier
+	}
+	return ""
+}
+
+// Rule is a single condition over the request context.
+type Rule struct {
+	Attribute string   `json:"attribute"`
+	Operator  string   `json:"operator"`
+	Values    []string `json:"values"` // Exactly one for gte / lt
+}
+
+// Rules is the condition set of a RULES campaign; every rule must match.
+type Rules []Rule
+
+// Match evaluates the rules against a request. A rule on an attribute the request
+// did not send never matches, so unknown clients are never targeted by accident.
+func (rs Rules) Match(rc RequestContext) bool {
+	for _, rule := range rs {
+		if !rule.match(rc.attr(rule.Attribute)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Rule) match(value string) bool {
+	if value == "" {
+		return false
+	}
+	switch r.Operator {
+	case OpIn:
+		return r.contains(value)
+	case OpNotIn:
+		return !r.contains(value)
+	case OpGTE, OpLT:
+		cmp, ok := compareVersions(value, r.Values[0])
+		if !ok {
+			return false
+		}
+		if r.Operator == OpGTE {
+			return cmp >= 0
+		}
+		return cmp < 0
+	}
+	return false
+}
+
+// contains compares case-insensitively; a bare language ("en") also matches its regional tags ("en-US").
+func (r Rule) contains(value string) bool {
+	for _, v := range r.Values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+		if r.Attribute == AttrLanguage && len(value) > len(v) && value[len(v)] == '-' && strings.EqualFold(value[:len(v)], v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks attributes, operators and values, so a bad rule fails on save rather than at request time.
+func (rs Rules) Validate() error {
+	if len(rs) == 0 {
+		return fmt.Errorf("%w: a RULES campaign needs at least one rule", ErrInvalidRules)
+	}
+	for i, r := range rs {
+		if !knownAttribute(r.Attribute) {
+			return fmt.Errorf("%w: rule %d: unknown attribute %q", ErrInvalidRules, i, r.Attribute)
+		}
+		switch r.Operator {
+		case OpIn, OpNotIn:
+			if len(r.Values) == 0 {
+				return fmt.Errorf("%w: rule %d: %s needs at least one value", ErrInvalidRules, i, r.Operator)
+			}
+		case OpGTE, OpLT:
+			if r.Attribute != AttrAppVersion {
+				return fmt.Errorf("%w: rule %d: %s only applies to %s", ErrInvalidRules, i, r.Operator, AttrAppVersion)
+			}
+			if len(r.Values) != 1 {
+				return fmt.Errorf("%w: rule %d: %s needs exactly one value", ErrInvalidRules, i, r.Operator)
+			}
+			if _, ok := parseVersion(r.Values[0]); !ok {
+				return fmt.Errorf("%w: rule %d: invalid version %q", ErrInvalidRules, i, r.Values[0])
+			}
+		default:
+			return fmt.Errorf("%w: rule %d: unknown operator %q", ErrInvalidRules, i, r.Operator)
+		}
+	}
+	return nil
+}
+
+func knownAttribute(name string) bool {
+	switch name {
+	case AttrPlatform, AttrAppVersion, AttrCountry, AttrLanguage, AttrTier:
+		return true
+	}
+	return false
+}
+
+// compareVersions compares dotted numeric versions; missing segments count as 0 ("5.1" == "5.1.0").
+func compareVersions(a, b string) (int, bool) {
+	va, ok := parseVersion(a)
+	if !ok {
+		return 0, false
+	}
+	vb, ok := parseVersion(b)
+	if !ok {
+		return 0, false
+	}
+	for i := 0; i < max(len(va), len(vb)); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if v == "" {
+		return nil, false
+	}
+	parts := strings.Split(v, ".")
+	out := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
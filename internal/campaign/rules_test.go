@@ -0,0 +1,38 @@
+package campaign
+
+import "testing"
+
+func TestRulesMatch(t *testing.T) {
+	ios := RequestContext{Platform: "ios", AppVersion: "5.12.1", Country: "US", Language: "en-US", Tier: "free"}
+	tests := []struct {
+		name  string
+		rules Rules
+		rc    RequestContext
+		want  bool
+	}{
+		{"no rules", nil, ios, true},
+		{"in", Rules{{AttrPlatform, OpIn, []string{"android", "ios"}}}, ios, true},
+		{"in ignores case", Rules{{AttrCountry, OpIn, []string{"us"}}}, ios, true},
+		{"not in list", Rules{{AttrPlatform, OpIn, []string{"android"}}}, ios, false},
+		{"not_in", Rules{{AttrTier, OpNotIn, []string{"premium"}}}, ios, true},
+		{"not_in listed", Rules{{AttrTier, OpNotIn, []string{"free"}}}, ios, false},
+		{"bare language matches regional tag", Rules{{AttrLanguage, OpIn, []string{"en"}}}, ios, true},
+		{"regional tag does not match bare language", Rules{{AttrLanguage, OpIn, []string{"en-GB"}}}, RequestContext{Language: "en"}, false},
+		{"language prefix needs a dash", Rules{{AttrLanguage, OpIn, []string{"e"}}}, ios, false},
+		{"gte equal", Rules{{AttrAppVersion, OpGTE, []string{"5.12.1"}}}, ios, true},
+		{"gte compares numerically", Rules{{AttrAppVersion, OpGTE, []string{"5.9"}}}, ios, true},
+		{"gte older", Rules{{AttrAppVersion, OpGTE, []string{"6"}}}, ios, false},
+		{"lt", Rules{{AttrAppVersion, OpLT, []string{"5.12.2"}}}, ios, true},
+		{"lt missing segments count as 0", Rules{{AttrAppVersion, OpLT, []string{"5.12.1.0"}}}, ios, false},
+		{"unparsable client version", Rules{{AttrAppVersion, OpGTE, []string{"1.0"}}}, RequestContext{AppVersion: "beta"}, false},
+		{"unknown attribute never matches", Rules{{AttrTier, OpNotIn, []string{"premium"}}}, RequestContext{Platform: "ios"}, false},
+		{"every rule must match", Rules{{AttrPlatform, OpIn, []string{"ios"}}, {AttrCountry, OpIn, []string{"DE"}}}, ios, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Match(tt.rc); got != tt.want {
+				t.Errorf("Match(%+v) = %v, want %v", tt.rc, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// LastMonthDay in Schedule.MonthDays matches the last day of every month (28th to 31st).
+const LastMonthDay = -1
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Schedule restricts a campaign to recurring slots within StartTime and EndTime, e.g. a payday
+// promo on the 25th ({"month_days":[25]}) or lunch deals ({"hours":[{"from":"11:00","to":"14:00"}]}).
+// Every non-empty field must match the local time in Timezone; an empty field matches always.
+// Weekdays and month days are those of the local date, so the hours after midnight of an
+// overnight range belong to the next day.
+type Schedule struct {
+	Timezone  string      `json:"timezone,omitempty"`   // IANA name, e.g. "Europe/Berlin" (default UTC)
+	Weekdays  []string    `json:"weekdays,omitempty"`   // "mon" to "sun"
+	MonthDays []int       `json:"month_days,omitempty"` // 1 to 31, or LastMonthDay
+	Hours     []HourRange `json:"hours,omitempty"`      // Any range may match
+}
+
+// HourRange is a local time-of-day range, "HH:MM" from inclusive to exclusive. A range ending
+// before it starts wraps past midnight ("22:00" to "02:00"); "24:00" ends at midnight.
+type HourRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// locations caches time zones by name: LoadLocation reads the tz database on every call.
+var locations sync.Map // string -> *time.Location
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// Validate checks the time zone, days and ranges, so a bad schedule fails on save rather than
+// silently never matching.
+func (s *Schedule) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Timezone == "Local" {
+		// LoadLocation accepts it, but it is the server's zone and differs between pods
+		return fmt.Errorf("%w: timezone must be an IANA name, not Local", ErrInvalidSchedule)
+	}
+	if _, err := loadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, s.Timezone)
+	}
+	for _, d := range s.Weekdays {
+		if _, ok := weekdayNames[d]; !ok {
+			return fmt.Errorf("%w: weekday %q must be one of mon, tue, wed, thu, fri, sat, sun", ErrInvalidSchedule, d)
+		}
+	}
+	for _, d := range s.MonthDays {
+		if (d < 1 || d > 31) && d != LastMonthDay {
+			return fmt.Errorf("%w: month day %d must be between 1 and 31, or %d for the last day", ErrInvalidSchedule, d, LastMonthDay)
+		}
+	}
+	for i, h := range s.Hours {
+		from, ok1 := parseClock(h.From)
+		to, ok2 := parseClock(h.To)
+		if !ok1 || !ok2 || from == 24*60 {
+			return fmt.Errorf("%w: hour range %d: from and to must be HH:MM", ErrInvalidSchedule, i)
+		}
+		if from == to {
+			return fmt.Errorf("%w: hour range %d is empty", ErrInvalidSchedule, i)
+		}
+	}
+	return nil
+}
+
+// Active reports whether t falls into the schedule. A nil schedule is always active.
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	loc, err := loadLocation(s.Timezone)
+	if err != nil {
+		return false // Validated on save; an unknown zone here means the tz database changed
+	}
+	t = t.In(loc)
+
+	if len(s.Weekdays) > 0 && !s.matchWeekday(t.Weekday()) {
+		return false
+	}
+	if len(s.MonthDays) > 0 && !s.matchMonthDay(t) {
+		return false
+	}
+	if len(s.Hours) > 0 && !s.matchHours(t.Hour()*60+t.Minute()) {
+		return false
+	}
+	return true
+}
+
+func (s *Schedule) matchWeekday(wd time.Weekday) bool {
+	for _, d := range s.Weekdays {
+		if weekdayNames[d] == wd {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) matchMonthDay(t time.Time) bool {
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, d := range s.MonthDays {
+		if d == t.Day() || (d == LastMonthDay && t.Day() == last) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) matchHours(minute int) bool {
+	for _, h := range s.Hours {
+		from, _ := parseClock(h.From)
+		to, _ := parseClock(h.To)
+		if from < to && minute >= from && minute < to {
+			return true
+		}
+		if from > to && (minute >= from || minute < to) { // Wraps past midnight
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses "HH:MM" (00:00 to 24:00) into minutes since midnight.
+func parseClock(s string) (int, bool) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok || len(hh) != 2 || len(mm) != 2 || strings.ContainsAny(s, "+-") {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(hh)
+	m, err2 := strconv.Atoi(mm)
+	if err1 != nil || err2 != nil || m > 59 || h*60+m > 24*60 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
@@ -0,0 +1,77 @@
+package campaign
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	// Europe/Berlin is UTC+2 from 2026-03-29; 2026-03-31 is a Tuesday
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		at       time.Time
+		want     bool
+	}{
+		{"nil schedule", nil, time.Date(2026, 3, 31, 10, 0, 0, 0, time.UTC), true},
+		{"empty schedule", &Schedule{}, time.Date(2026, 3, 31, 10, 0, 0, 0, time.UTC), true},
+		{"weekday", &Schedule{Timezone: "Europe/Berlin", Weekdays: []string{"tue"}}, time.Date(2026, 3, 31, 10, 0, 0, 0, time.UTC), true},
+		{"weekday of the local date", &Schedule{Timezone: "Europe/Berlin", Weekdays: []string{"tue"}}, time.Date(2026, 3, 30, 22, 30, 0, 0, time.UTC), true},
+		{"other weekday", &Schedule{Timezone: "Europe/Berlin", Weekdays: []string{"tue"}}, time.Date(2026, 3, 31, 22, 30, 0, 0, time.UTC), false},
+		{"month day", &Schedule{MonthDays: []int{25}}, time.Date(2026, 3, 25, 12, 0, 0, 0, time.UTC), true},
+		{"other month day", &Schedule{MonthDays: []int{25}}, time.Date(2026, 3, 26, 12, 0, 0, 0, time.UTC), false},
+		{"last day of a long month", &Schedule{MonthDays: []int{LastMonthDay}}, time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC), true},
+		{"last day of february", &Schedule{MonthDays: []int{LastMonthDay}}, time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC), true},
+		{"not the last day", &Schedule{MonthDays: []int{LastMonthDay}}, time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC), false},
+		{"hour range start is inclusive", &Schedule{Hours: []HourRange{{"11:00", "14:00"}}}, time.Date(2026, 3, 31, 11, 0, 0, 0, time.UTC), true},
+		{"hour range end is exclusive", &Schedule{Hours: []HourRange{{"11:00", "14:00"}}}, time.Date(2026, 3, 31, 14, 0, 0, 0, time.UTC), false},
+		{"hours in the time zone", &Schedule{Timezone: "Europe/Berlin", Hours: []HourRange{{"11:00", "14:00"}}}, time.Date(2026, 3, 31, 9, 30, 0, 0, time.UTC), true},
+		{"overnight range before midnight", &Schedule{Hours: []HourRange{{"22:00", "02:00"}}}, time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC), true},
+		{"overnight range after midnight", &Schedule{Hours: []HourRange{{"22:00", "02:00"}}}, time.Date(2026, 3, 31, 1, 59, 0, 0, time.UTC), true},
+		{"outside overnight range", &Schedule{Hours: []HourRange{{"22:00", "02:00"}}}, time.Date(2026, 3, 31, 2, 0, 0, 0, time.UTC), false},
+		{"range ending at 24:00", &Schedule{Hours: []HourRange{{"20:00", "24:00"}}}, time.Date(2026, 3, 31, 23, 59, 0, 0, time.UTC), true},
+		{"any range may match", &Schedule{Hours: []HourRange{{"08:00", "09:00"}, {"17:00", "18:00"}}}, time.Date(2026, 3, 31, 17, 30, 0, 0, time.UTC), true},
+		{"every field must match", &Schedule{Weekdays: []string{"sat", "sun"}, Hours: []HourRange{{"11:00", "14:00"}}}, time.Date(2026, 4, 3, 12, 0, 0, 0, time.UTC), false},
+		{"unknown time zone", &Schedule{Timezone: "Mars/Olympus"}, time.Date(2026, 3, 31, 10, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := FixedClock(tt.at).Now()
+			if got := tt.schedule.Active(now); got != tt.want {
+				t.Errorf("Active(%s) = %v, want %v", now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		wantErr  bool
+	}{
+		{"nil schedule", nil, false},
+		{"default time zone", &Schedule{Hours: []HourRange{{"11:00", "14:00"}}}, false},
+		{"IANA time zone", &Schedule{Timezone: "Europe/Berlin", Weekdays: []string{"mon"}, MonthDays: []int{1, LastMonthDay}}, false},
+		{"overnight range", &Schedule{Hours: []HourRange{{"22:00", "24:00"}, {"00:00", "02:00"}}}, false},
+		{"Local time zone", &Schedule{Timezone: "Local"}, true},
+		{"unknown time zone", &Schedule{Timezone: "Mars/Olympus"}, true},
+		{"unknown weekday", &Schedule{Weekdays: []string{"monday"}}, true},
+		{"month day out of range", &Schedule{MonthDays: []int{32}}, true},
+		{"malformed hour", &Schedule{Hours: []HourRange{{"9:00", "10:00"}}}, true},
+		{"range starting at 24:00", &Schedule{Hours: []HourRange{{"24:00", "02:00"}}}, true},
+		{"empty range", &Schedule{Hours: []HourRange{{"10:00", "10:00"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.Validate()
+			if tt.wantErr && !errors.Is(err, ErrInvalidSchedule) {
+				t.Errorf("Validate() = %v, want ErrInvalidSchedule", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+		})
+	}
+}
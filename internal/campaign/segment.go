@@ -0,0 +1,60 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrSegmentNotFound    = errors.New("segment not found")
+	ErrSegmentExists      = errors.New("segment already exists")
+	ErrSegmentInUse       = errors.New("segment is referenced by campaigns")
+	ErrInvalidSegmentName = errors.New("segment name must be 1-64 characters of letters, digits, '_' or '-'")
+	ErrUsesNamedSegment   = errors.New("campaign targets a named segment, manage its members via /admin/segments")
+)
+
+// segmentNamePattern keeps names safe to embed in Redis keys.
+var segmentNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Segment is a named, reusable list of users (e.g. "VIP", "new_users") that many campaigns
+// can reference through Campaign.TargetSegment.
+type Segment struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func ValidSegmentName(name string) bool {
+	return segmentNamePattern.MatchString(name)
+}
+
+// Audience identifies a list of targeted users: either a campaign's own whitelist
+// (campaign_targets, campaign:{id}:users) or a named segment (segment_members, segment:{name}:users).
+type Audience struct {
+	CampaignID int64
+	Segment    string
+}
+
+func CampaignAudience(campaignID int64) Audience { return Audience{CampaignID: campaignID} }
+func SegmentAudience(name string) Audience       { return Audience{Segment: name} }
+
+func (a Audience) IsSegment() bool { return a.Segment != "" }
+
+// String names the audience in reports: "campaign:{id}" or "segment:{name}".
+func (a Audience) String() string {
+	if a.IsSegment() {
+		return "segment:" + a.Segment
+	}
+	return fmt.Sprintf("campaign:%d", a.CampaignID)
+}
+
+// Audience returns where a SEGMENT campaign's members live: its named segment when set,
+// otherwise its own whitelist.
+func (c *Campaign) Audience() Audience {
+	if c.TargetSegment != "" {
+		return SegmentAudience(c.TargetSegment)
+	}
+	return CampaignAudience(c.ID)
+}
@@ -0,0 +1,141 @@
+package campaign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidServeToken = errors.New("invalid serve token")
+
+const (
+	minServeTokenKeyLength = 32
+	serveTokenClockSkew    = time.Minute // Tolerated between pods issuing and verifying
+)
+
+var serveTokenKeyID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ServeTokens issues and verifies the HMAC tokens that tie an event to a popup actually served.
+// A token is "kid.user.campaign.variant.issued.signature", signed with HMAC-SHA256 by the current key.
+// The variant is the A/B variant served (empty without an experiment), so events record what was shown.
+// Older keys stay valid for verification, so keys can be rotated without rejecting tokens in flight.
+type ServeTokens struct {
+	ttl  time.Duration
+	keys atomic.Pointer[keyring]
+}
+
+type keyring struct {
+	current string // Key ID used to sign
+	secrets map[string][]byte
+}
+
+func NewServeTokens(ttl time.Duration) *ServeTokens {
+	return &ServeTokens{ttl: ttl}
+}
+
+// SetKeys replaces the keyring from a spec "kid:secret,kid:secret,...". The first key signs,
+// all of them verify. Secrets must be at least 32 bytes.
+func (t *ServeTokens) SetKeys(spec string) error {
+	kr := &keyring{secrets: map[string][]byte{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(entry, ":")
+		if !ok || !serveTokenKeyID.MatchString(kid) {
+			return fmt.Errorf("serve token key %q: want kid:secret with kid matching %s", kid, serveTokenKeyID)
+		}
+		if len(secret) < minServeTokenKeyLength {
+			return fmt.Errorf("serve token key %q: secret must be at least %d bytes", kid, minServeTokenKeyLength)
+		}
+		if _, dup := kr.secrets[kid]; dup {
+			return fmt.Errorf("serve token key %q: duplicate key id", kid)
+		}
+		if kr.current == "" {
+			kr.current = kid
+		}
+		kr.secrets[kid] = []byte(secret)
+	}
+	if kr.current == "" {
+		return errors.New("no serve token keys configured")
+	}
+	t.keys.Store(kr)
+	return nil
+}
+
+// Issue signs a token for a popup, showing variantID ("" for none), served to userID at time at.
+func (t *ServeTokens) Issue(userID, campaignID int64, variantID string, at time.Time) (string, error) {
+	kr := t.keys.Load()
+	if kr == nil {
+		return "", errors.New("no serve token keys configured")
+	}
+	payload := fmt.Sprintf("%s.%d.%d.%s.%d", kr.current, userID, campaignID, variantID, at.Unix())
+	return payload + "." + sign(kr.secrets[kr.current], payload), nil
+}
+
+// Verify checks the signature, that the token was issued for this user and campaign, and that it has
+// not expired. It returns the variant served.
+func (t *ServeTokens) Verify(token string, userID, campaignID int64, at time.Time) (string, error) {
+	kr := t.keys.Load()
+	if kr == nil {
+		return "", fmt.Errorf("%w: no keys configured", ErrInvalidServeToken)
+	}
+	if token == "" {
+		return "", fmt.Errorf("%w: missing", ErrInvalidServeToken)
+	}
+
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", fmt.Errorf("%w: malformed", ErrInvalidServeToken)
+	}
+	payload, sig := token[:i], token[i+1:]
+	parts := strings.Split(payload, ".")
+	if len(parts) == 4 {
+		// Issued before tokens carried the variant; accepted until they expire
+		parts = []string{parts[0], parts[1], parts[2], "", parts[3]}
+	}
+	if len(parts) != 5 {
+		return "", fmt.Errorf("%w: malformed", ErrInvalidServeToken)
+	}
+
+	secret, ok := kr.secrets[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown key", ErrInvalidServeToken)
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign(secret, payload))) {
+		return "", fmt.Errorf("%w: bad signature", ErrInvalidServeToken)
+	}
+
+	if parts[1] != strconv.FormatInt(userID, 10) || parts[2] != strconv.FormatInt(campaignID, 10) {
+		return "", fmt.Errorf("%w: issued for another user or campaign", ErrInvalidServeToken)
+	}
+	issued, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed", ErrInvalidServeToken)
+	}
+	issuedAt := time.Unix(issued, 0)
+	if issuedAt.After(at.Add(serveTokenClockSkew)) || at.After(issuedAt.Add(t.ttl)) {
+		return "", fmt.Errorf("%w: expired", ErrInvalidServeToken)
+	}
+	return parts[3], nil
+}
+
+// ReplayID names the single event of the given action a verified token may record, as a claim for
+// Repository.ClaimEvent: a token replayed within its TTL must not count again.
+func ReplayID(token string, action EventAction) string {
+	return "token:" + string(action) + ":" + token[strings.LastIndexByte(token, '.')+1:]
+}
+
+func sign(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
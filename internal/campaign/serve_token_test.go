@@ -0,0 +1,85 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeTokensVerify(t *testing.T) {
+	oldKey := "old:" + strings.Repeat("o", minServeTokenKeyLength)
+	newKey := "new:" + strings.Repeat("n", minServeTokenKeyLength)
+	clock := FixedClock(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	tokens := NewServeTokens(time.Hour)
+	if err := tokens.SetKeys(oldKey); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := tokens.Issue(7, 42, "", clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.SetKeys(newKey + "," + oldKey); err != nil { // Rotated: new signs, old still verifies
+		t.Fatal(err)
+	}
+	token, _ := tokens.Issue(7, 42, "b", clock.Now())
+	future, _ := tokens.Issue(7, 42, "b", clock.Now().Add(2*serveTokenClockSkew))
+	skewed, _ := tokens.Issue(7, 42, "b", clock.Now().Add(serveTokenClockSkew/2))
+	legacyPayload := fmt.Sprintf("new.7.42.%d", clock.Now().Unix()) // Issued before tokens carried the variant
+	legacy := legacyPayload + "." + sign([]byte(newKey[len("new:"):]), legacyPayload)
+
+	i := strings.LastIndexByte(token, '.')
+	tests := []struct {
+		name       string
+		token      string
+		userID     int64
+		campaignID int64
+		at         time.Time
+		wantErr    bool
+		wantVar    string
+	}{
+		{"valid", token, 7, 42, clock.Now(), false, "b"},
+		{"valid until the ttl", token, 7, 42, clock.Now().Add(time.Hour), false, "b"},
+		{"signed by an older key", oldToken, 7, 42, clock.Now(), false, ""},
+		{"issued slightly ahead of this pod", skewed, 7, 42, clock.Now(), false, "b"},
+		{"without variant", legacy, 7, 42, clock.Now(), false, ""},
+		{"expired", token, 7, 42, clock.Now().Add(time.Hour + time.Second), true, ""},
+		{"issued in the future", future, 7, 42, clock.Now(), true, ""},
+		{"another user", token, 8, 42, clock.Now(), true, ""},
+		{"another campaign", token, 7, 43, clock.Now(), true, ""},
+		{"missing", "", 7, 42, clock.Now(), true, ""},
+		{"malformed", "garbage", 7, 42, clock.Now(), true, ""},
+		{"tampered payload", strings.Replace(token, ".7.", ".8.", 1), 8, 42, clock.Now(), true, ""},
+		{"tampered variant", strings.Replace(token, ".b.", ".a.", 1), 7, 42, clock.Now(), true, ""},
+		{"tampered signature", token[:i+1] + strings.Repeat("A", len(token)-i-1), 7, 42, clock.Now(), true, ""},
+		{"unknown key", "gone" + token[strings.IndexByte(token, '.'):], 7, 42, clock.Now(), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variantID, err := tokens.Verify(tt.token, tt.userID, tt.campaignID, tt.at)
+			if tt.wantErr && !errors.Is(err, ErrInvalidServeToken) {
+				t.Errorf("Verify() = %v, want ErrInvalidServeToken", err)
+			}
+			if !tt.wantErr && (err != nil || variantID != tt.wantVar) {
+				t.Errorf("Verify() = %q, %v, want %q, nil", variantID, err, tt.wantVar)
+			}
+		})
+	}
+
+	t.Run("no keys", func(t *testing.T) {
+		if _, err := NewServeTokens(time.Hour).Verify(token, 7, 42, clock.Now()); !errors.Is(err, ErrInvalidServeToken) {
+			t.Errorf("Verify() = %v, want ErrInvalidServeToken", err)
+		}
+	})
+	t.Run("retired key", func(t *testing.T) {
+		retired := NewServeTokens(time.Hour)
+		if err := retired.SetKeys(newKey); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := retired.Verify(oldToken, 7, 42, clock.Now()); !errors.Is(err, ErrInvalidServeToken) {
+			t.Errorf("Verify() = %v, want ErrInvalidServeToken", err)
+		}
+	})
+}
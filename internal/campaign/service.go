@@ -1,112 +1,360 @@
 package campaign
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
 	"time"
 )
 
 type Service struct {
-	repo  Repository // Redis
-	store Store      // Postgres
+	repo     Repository   // Redis
+	store    Store        // Postgres
+	events   EventSink    // Event log, synchronous to the Store unless SetEventSink is called
+	tokens   *ServeTokens // Nil = serve tokens neither issued nor required
+	clock    Clock
+	rotation Rotation // Order within a priority tier
 }
 
 func NewService(repo Repository, store Store) *Service {
-	return &Service{repo: repo, store: store}
+	return &Service{repo: repo, store: store, events: storeSink{store}, clock: SystemClock{}, rotation: RotationOff}
 }
 
-// GetPopup determines which popup to show for a user.
-func (s *Service) GetPopup(ctx context.Context, userID int64) (*Campaign, error) {
-	// 1. Get ALL active campaign IDs (Already Sorted by Priority in Redis ZSET)
-	activeIDs, err := s.repo.GetActiveCampaignIDs(ctx)
-	if err != nil {
-		return nil, err // Or fail silent returning nil
-	}
-	if len(activeIDs) == 0 {
-		return nil, nil // No active campaigns
+// SetRotation changes how campaigns sharing a priority are ordered (RotationOff by default).
+func (s *Service) SetRotation(mode Rotation) {
+	s.rotation = mode
+}
+
+// SetClock replaces the wall clock, e.g. with a FixedClock in tests.
+func (s *Service) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetEventSink routes recorded events elsewhere, typically to an EventWriter.
+func (s *Service) SetEventSink(sink EventSink) {
+	s.events = sink
+}
+
+// SetServeTokens makes GetPopup issue serve tokens and events require a valid one.
+func (s *Service) SetServeTokens(tokens *ServeTokens) {
+	s.tokens = tokens
+}
+
+// Popup is what GetPopup serves: the winning campaign plus the token to echo on its events.
+type Popup struct {
+	*Campaign
+	VariantID  string `json:"variant_id,omitempty"` // A/B variant shown, its creative is already applied
+	ServeToken string `json:"serve_token,omitempty"`
+}
+
+// MaxPopups bounds GetPopups: enough for a carousel, not a feed.
+const MaxPopups = 20
+
+// GetPopup determines which popup to show for a user on a placement (empty = DefaultPlacement),
+// given the context of their request. Each placement is resolved independently.
+func (s *Service) GetPopup(ctx context.Context, userID int64, placement string, rc RequestContext) (*Popup, error) {
+	popups, err := s.GetPopups(ctx, userID, placement, rc, 1)
+	if err != nil || len(popups) == 0 {
+		return nil, err
 	}
+	return popups[0], nil
+}
 
-	// 2. Fetch Metadata for ALL candidates (Pipeline)
-	campMap, err := s.repo.GetCampaignsMetadata(ctx, activeIDs)
+// GetPopups returns up to limit (at most MaxPopups) eligible campaigns of a placement in priority
+// order, with the same checks as GetPopup. Each popup carries its own serve token.
+func (s *Service) GetPopups(ctx context.Context, userID int64, placement string, rc RequestContext, limit int) ([]*Popup, error) {
+	placement, err := resolvePlacement(placement)
 	if err != nil {
 		return nil, err
 	}
+	limit = min(limit, MaxPopups)
+	now := s.clock.Now()
 
-	// 3. Evaluation Loop (Highest Priority First)
-	now := time.Now()
+	// 1. Fetch the placement's active IDs (sorted by priority), metadata, targeting & impressions in 1 round-trip
+	snap, err := s.repo.GetEvaluationSnapshot(ctx, userID, placement, now)
+	if err != nil {
+		return nil, err // Or fail silent returning nil
+	}
 
-	for _, id := range activeIDs {
-		camp, exists := campMap[id]
-		if !exists {
+	// 2. User policy: cooldown and limits across all campaigns
+	popups := []*Popup{}
+	remaining, _ := snap.Policy.remaining(snap.Activity, now)
+	if remaining == 0 {
+		return popups, nil
+	}
+	if remaining > 0 {
+		limit = min(limit, remaining)
+	}
+
+	// 3. Evaluation Loop (Highest Priority First, ties rotated by weight), purely in memory
+	for _, id := range rotate(s.rotation, snap.ActiveIDs, snap.Campaigns, userID) {
+		if len(popups) >= limit {
+			break
+		}
+		if snap.reject(id, rc, now) != "" {
 			continue
 		}
+		// Winner Found!
+		p, err := s.serve(userID, snap.Campaigns[id], now)
+		if err != nil {
+			return nil, err
+		}
+		popups = append(popups, p)
+	}
 
-		// A. Time Check
-		if now.Before(camp.StartTime) || now.After(camp.EndTime) {
-			continue
+	return popups, nil
+}
+
+// reject returns why campaign id cannot be served from this snapshot, or "" if it is eligible.
+func (snap *EvaluationSnapshot) reject(id int64, rc RequestContext, now time.Time) RejectReason {
+	camp, exists := snap.Campaigns[id]
+	if !exists {
+		return RejectMissingMetadata
+	}
+
+	// A. Time Check
+	if now.Before(camp.StartTime) {
+		return RejectNotStarted
+	}
+	if now.After(camp.EndTime) {
+		return RejectEnded
+	}
+	if !camp.Schedule.Active(now) {
+		return RejectOffSchedule
+	}
+
+	// B. Target Check (Whitelist/Segment/Rules)
+	if camp.TargetType == TargetTypeSegment && !snap.Targeted[id] {
+		return RejectNotTargeted
+	}
+	if camp.TargetType == TargetTypeRules && !camp.Rules.Match(rc) {
+		return RejectRulesNotMatched
+	}
+
+	// C. Frequency Cap Check
+	if camp.Cap().Reached(snap.Impressions[id]) {
+		return RejectCapReached
+	}
+	if camp.ClickCap.Reached(snap.Clicks[id]) {
+		return RejectClickCapReached
+	}
+	if camp.DismissHides && snap.Dismissed[id] {
+		return RejectDismissed
+	}
+
+	// D. Budget Check (shared by all users)
+	if allowance := camp.DeliveryAllowance(now); allowance >= 0 && snap.Delivered[id] >= allowance {
+		if snap.Delivered[id] >= camp.Budget {
+			return RejectBudgetSpent
 		}
+		return RejectAheadOfPace
+	}
+	return ""
+}
 
-		// B. Target Check
-		isTargeted := true
-		if camp.TargetType == TargetTypeSegment {
-			// Check Whitelist/Segment
-			isTargeted, err = s.repo.IsUserTargeted(ctx, id, userID)
-			if err != nil {
-				// Log error, skip this campaign
-				continue
-			}
+func (s *Service) serve(userID int64, camp *Campaign, now time.Time) (*Popup, error) {
+	p := &Popup{Campaign: camp}
+	if v := camp.VariantFor(userID); v != nil {
+		p.Campaign, p.VariantID = camp.withVariant(v), v.ID
+	}
+	if s.tokens != nil {
+		token, err := s.tokens.Issue(userID, camp.ID, p.VariantID, now)
+		if err != nil {
+			return nil, err
 		}
+		p.ServeToken = token
+	}
+	return p, nil
+}
 
-		if !isTargeted {
-			continue
+// EventInput is an event as reported by a client.
+type EventInput struct {
+	UserID     int64
+	CampaignID int64
+	Action     EventAction
+	EventID    string // Optional; a retry with the same ID is acknowledged without counting it again
+	ServeToken string // From GetPopup; required when serve tokens are enabled
+	VariantID  string // From GetPopup; the variant signed into the serve token wins when tokens are enabled
+}
+
+func (s *Service) RegisterImpression(ctx context.Context, in EventInput) error {
+	in.Action = ActionView
+	return s.RecordEvent(ctx, in)
+}
+
+// RecordEvent logs a VIEW, CLICK or DISMISS to the event sink and updates the hot-path counters
+// that GetPopup evaluates (frequency cap, click cap, dismissal).
+func (s *Service) RecordEvent(ctx context.Context, in EventInput) error {
+	if !in.Action.Valid() {
+		return ErrInvalidAction
+	}
+	if len(in.EventID) > MaxEventIDLength {
+		return ErrInvalidEventID
+	}
+	now := s.clock.Now()
+	if s.tokens != nil {
+		variantID, err := s.tokens.Verify(in.ServeToken, in.UserID, in.CampaignID, now)
+		if err != nil {
+			return err
 		}
+		in.VariantID = variantID
+	}
 
-		// C. Frequency Cap Check
-		impressionsMap, err := s.repo.GetUserImpressions(ctx, userID, []int64{id})
+	// Metadata is needed to size the lifetime window (campaign end)
+	campMap, err := s.repo.GetCampaignsMetadata(ctx, []int64{in.CampaignID})
+	if err != nil {
+		return err
+	}
+	camp, ok := campMap[in.CampaignID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	var claims []string
+	if in.EventID != "" {
+		claims = append(claims, in.EventID)
+	}
+	if s.tokens != nil {
+		claims = append(claims, ReplayID(in.ServeToken, in.Action))
+	}
+	for i, id := range claims {
+		first, err := s.repo.ClaimEvent(ctx, in.UserID, id)
 		if err != nil {
-			continue
+			return errors.Join(err, s.releaseEvent(ctx, in.UserID, claims[:i]))
 		}
-		
-		seenCount := impressionsMap[id]
-		if seenCount >= camp.MaxFrequency {
-			continue // Cap reached
+		if !first {
+			// Retry of a recorded event, or a replayed token
+			return s.releaseEvent(ctx, in.UserID, claims[:i])
 		}
+	}
 
-		// D. Winner Found!
-		return camp, nil
+	if err := s.countEvent(ctx, in, camp, now); err != nil {
+		// The retry must count it; if the log write got through, its duplicate row is dropped
+		return errors.Join(err, s.releaseEvent(ctx, in.UserID, claims))
 	}
+	return nil
+}
 
-	return nil, nil // No eligible campaign found
+// releaseEvent undoes the claims of an event that was not recorded.
+func (s *Service) releaseEvent(ctx context.Context, userID int64, claims []string) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for _, id := range claims {
+		errs = append(errs, s.repo.ReleaseEvent(ctx, userID, id))
+	}
+	return errors.Join(errs...)
 }
 
-func (s *Service) RegisterImpression(ctx context.Context, userID int64, campaignID int64) error {
-	return s.repo.IncrementImpression(ctx, userID, campaignID)
+// countEvent writes the event to the sink and updates the hot-path counters.
+func (s *Service) countEvent(ctx context.Context, in EventInput, camp *Campaign, now time.Time) error {
+	// The variant shown, not the one assigned now: weights may have changed since the popup was served
+	variantID := in.VariantID
+	if !camp.hasVariant(variantID) {
+		variantID = "" // An experiment that ended, or an unsigned ID that names no variant
+	}
+	if err := s.events.Write(ctx, Event{EventID: in.EventID, CampaignID: in.CampaignID, VariantID: variantID, UserID: in.UserID, Action: in.Action, At: now}); err != nil {
+		return err
+	}
+
+	switch in.Action {
+	case ActionClick:
+		return s.repo.IncrementClick(ctx, in.UserID, camp, now)
+	case ActionDismiss:
+		return s.repo.MarkDismissed(ctx, in.UserID, camp, now)
+	default:
+		return s.repo.IncrementImpression(ctx, in.UserID, camp, now)
+	}
 }
 
 // --- CRUD / Admin ---
 
 func (s *Service) CreateCampaign(ctx context.Context, c *Campaign) error {
+	if err := s.checkCampaign(ctx, c); err != nil {
+		return err
+	}
 	// 1. Save to DB (Single Source of Truth)
 	if err := s.store.Create(ctx, c); err != nil {
 		return err
 	}
 	// 2. Sync to Redis (Cache / Hot Path)
-	return s.repo.SaveCampaign(ctx, c)
+	if err := s.repo.SaveCampaign(ctx, c); err != nil {
+		return err
+	}
+	return s.restoreDelivered(ctx, []*Campaign{c})
 }
 
 func (s *Service) UpdateCampaign(ctx context.Context, c *Campaign) error {
+	if err := s.checkCampaign(ctx, c); err != nil {
+		return err
+	}
 	// 1. Update DB
 	if err := s.store.Update(ctx, c); err != nil {
 		return err
 	}
 	// 2. Sync Redis
-	return s.repo.SaveCampaign(ctx, c)
+	if err := s.repo.SaveCampaign(ctx, c); err != nil {
+		return err
+	}
+	// A budget may have been added after impressions were served, or the counter lost
+	return s.restoreDelivered(ctx, []*Campaign{c})
+}
+
+// restoreDelivered repairs the hot-path delivery counters of budgeted campaigns from the event log.
+func (s *Service) restoreDelivered(ctx context.Context, campaigns []*Campaign) error {
+	var ids []int64
+	for _, c := range campaigns {
+		if c.Budget > 0 {
+			ids = append(ids, c.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	counts, err := s.store.CountImpressions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, c := range campaigns {
+		if c.Budget > 0 {
+			if err := s.repo.RestoreDelivered(ctx, c, counts[c.ID], s.clock.Now()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkCampaign validates a campaign before it is written, defaulting its placement.
+func (s *Service) checkCampaign(ctx context.Context, c *Campaign) error {
+	if err := checkPlacement(c); err != nil {
+		return err
+	}
+	if c.Weight < 0 {
+		return ErrInvalidWeight
+	}
+	if err := checkBudget(c); err != nil {
+		return err
+	}
+	if err := c.Schedule.Validate(); err != nil {
+		return err
+	}
+	if err := c.Variants.Validate(); err != nil {
+		return err
+	}
+	return s.checkTargeting(ctx, c)
 }
 
 func (s *Service) DeleteCampaign(ctx context.Context, id int64) error {
 	if err := s.store.Delete(ctx, id); err != nil {
 		return err
 	}
-	return s.repo.RemoveCampaign(ctx, id)
+	if err := s.repo.RemoveCampaign(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.ReplaceTargetUsers(ctx, CampaignAudience(id), nil) // Drop the bitmap too
 }
 
 func (s *Service) ListCampaigns(ctx context.Context) ([]*Campaign, error) {
@@ -117,18 +365,155 @@ func (s *Service) GetCampaign(ctx context.Context, id int64) (*Campaign, error)
 	return s.store.GetByID(ctx, id) // DB Only
 }
 
-func (s *Service) SyncCampaigns(ctx context.Context) error {
-	// 1. Fetch All from DB
-	list, err := s.store.List(ctx)
+// SyncReport describes the difference between the DB and the hot-path cache.
+type SyncReport struct {
+	DryRun    bool     `json:"dry_run"`
+	Missing   []int64  `json:"missing"`   // Live in DB, absent from cache
+	Stale     []int64  `json:"stale"`     // Cached with outdated metadata, priority or placement
+	Orphaned  []int64  `json:"orphaned"`  // Cached but deleted, inactive or expired in DB
+	Audiences []string `json:"audiences"` // Target bitmaps of live campaigns absent from cache, e.g. "segment:vip"
+	Unchanged int      `json:"unchanged"`
+}
+
+// Changed reports whether the cache differed from the DB.
+func (r *SyncReport) Changed() bool {
+	return len(r.Missing)+len(r.Stale)+len(r.Orphaned)+len(r.Audiences) > 0
+}
+
+// SyncCampaigns reconciles the cache with the DB and applies the diff.
+func (s *Service) SyncCampaigns(ctx context.Context) (*SyncReport, error) {
+	return s.Reconcile(ctx, false)
+}
+
+// Reconcile computes the diff between live campaigns in the DB and the cache and,
+// unless dryRun, applies it so the cache holds exactly the live campaigns. Delivery counters of
+// budgeted campaigns are raised to the DB count as well.
+func (s *Service) Reconcile(ctx context.Context, dryRun bool) (*SyncReport, error) {
+	// 1. Desired state: live campaigns from DB (no LIMIT)
+	list, err := s.store.ListActive(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// 2. Actual state: everything currently cached
+	cached, err := s.repo.GetCacheState(ctx)
+	if err != nil {
+		return nil, err
 	}
-	
-	// 2. Push to Redis
+
+	// 3. Diff
+	report := &SyncReport{DryRun: dryRun, Missing: []int64{}, Stale: []int64{}, Orphaned: []int64{}, Audiences: []string{}}
+	var toSave []*Campaign
+	live := make(map[int64]bool, len(list))
 	for _, c := range list {
+		live[c.ID] = true
+
+		meta, hasMeta := cached.Metadata[c.ID]
+		score, isActive := cached.Active[c.ID]
+		placed := cached.Placements[c.ID]
+		switch {
+		case !hasMeta || !isActive || len(placed) == 0:
+			report.Missing = append(report.Missing, c.ID)
+		case score != c.Priority || !sameCampaign(meta, c) || len(placed) != 1 || placed[0] != c.PlacementName():
+			report.Stale = append(report.Stale, c.ID)
+		default:
+			report.Unchanged++
+			continue
+		}
+		toSave = append(toSave, c)
+	}
+
+	orphans := map[int64]bool{}
+	for id := range cached.Metadata {
+		if !live[id] {
+			orphans[id] = true
+		}
+	}
+	for id := range cached.Active {
+		if !live[id] {
+			orphans[id] = true
+		}
+	}
+	for id := range cached.Placements {
+		if !live[id] {
+			orphans[id] = true
+		}
+	}
+	// A campaign created after the DB read is already cached but not in list: re-read
+	// every orphan so only those the DB still does not hold live are removed
+	for id := range orphans {
+		c, err := s.store.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil && c.IsActive && c.EndTime.After(s.clock.Now()) {
+			continue
+		}
+		report.Orphaned = append(report.Orphaned, id)
+	}
+	sort.Slice(report.Orphaned, func(i, j int) bool { return report.Orphaned[i] < report.Orphaned[j] })
+
+	// Bitmaps are not part of the metadata SaveCampaign restores (e.g. after a Redis flush).
+	// Only audiences that have members in the DB count as missing: an empty one has no key
+	var audiences []Audience
+	for _, c := range list {
+		a := c.Audience()
+		if c.TargetType != TargetTypeSegment || cached.Audiences[a] || slices.Contains(audiences, a) {
+			continue
+		}
+		page, err := s.store.ListTargets(ctx, a, -1, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) > 0 {
+			audiences = append(audiences, a)
+			report.Audiences = append(report.Audiences, a.String())
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	// 4. Apply (the user policy is small, it is simply pushed again)
+	policy, err := s.store.GetUserPolicy(ctx)
+	if err != nil {
+		return report, err
+	}
+	if policy != nil {
+		if err := s.repo.SaveUserPolicy(ctx, policy); err != nil {
+			return report, err
+		}
+	}
+	for _, c := range toSave {
 		if err := s.repo.SaveCampaign(ctx, c); err != nil {
-			return err
+			return report, err
 		}
 	}
-	return nil
+	for _, id := range report.Orphaned {
+		if err := s.repo.RemoveCampaign(ctx, id); err != nil {
+			return report, err
+		}
+	}
+	for _, a := range audiences {
+		if err := s.restoreAudience(ctx, a); err != nil {
+			return report, err
+		}
+	}
+	// Delivery counters are not compared: they only move up, so every budgeted campaign is repaired
+	if err := s.restoreDelivered(ctx, list); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// sameCampaign compares the cached fields of two campaigns, ignoring time zone representation.
+func sameCampaign(a, b *Campaign) bool {
+	x, y := *a, *b
+	x.StartTime, x.EndTime = x.StartTime.UTC(), x.EndTime.UTC()
+	y.StartTime, y.EndTime = y.StartTime.UTC(), y.EndTime.UTC()
+
+	xb, err1 := json.Marshal(x)
+	yb, err2 := json.Marshal(y)
+	return err1 == nil && err2 == nil && bytes.Equal(xb, yb)
 }
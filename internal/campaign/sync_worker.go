@@ -0,0 +1,67 @@
+package campaign
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SyncStatus reports the health of the background DB -> Redis sync.
+type SyncStatus struct {
+	Running             bool        `json:"running"`
+	Interval            string      `json:"interval"`
+	LastAttempt         time.Time   `json:"last_attempt"`
+	LastSuccess         time.Time   `json:"last_success"`
+	LagSeconds          float64     `json:"lag_seconds"` // Time since last successful sync
+	LastError           string      `json:"last_error,omitempty"`
+	ConsecutiveFailures int         `json:"consecutive_failures"`
+	TotalFailures       int         `json:"total_failures"`
+	LastReport          *SyncReport `json:"last_report,omitempty"`
+}
+
+// SyncWorker periodically reconciles Redis with PostgreSQL.
+// A failing or panicking run is logged and retried on the next tick; the loop never dies
+// until its context is cancelled.
+type SyncWorker struct {
+	worker periodicWorker[*SyncReport]
+}
+
+func NewSyncWorker(svc *Service, interval time.Duration) *SyncWorker {
+	return &SyncWorker{worker: periodicWorker[*SyncReport]{
+		name:     "campaign sync",
+		interval: interval,
+		job: func(ctx context.Context, _ time.Time) (*SyncReport, error) {
+			return svc.SyncCampaigns(ctx)
+		},
+		done: func(report *SyncReport, took time.Duration) {
+			if report.Changed() {
+				log.Printf("campaign sync: %d missing, %d stale, %d orphaned, %d audiences fixed in %s",
+					len(report.Missing), len(report.Stale), len(report.Orphaned), len(report.Audiences), took)
+			}
+		},
+	}}
+}
+
+// Run syncs immediately, then every interval, until ctx is cancelled.
+func (w *SyncWorker) Run(ctx context.Context) {
+	w.worker.Run(ctx)
+}
+
+// Status returns a copy of the current status with an up-to-date lag.
+func (w *SyncWorker) Status() SyncStatus {
+	st := w.worker.snapshot()
+	status := SyncStatus{
+		Running:             st.running,
+		Interval:            w.worker.interval.String(),
+		LastAttempt:         st.lastAttempt,
+		LastSuccess:         st.lastSuccess,
+		LastError:           st.lastError,
+		ConsecutiveFailures: st.consecutiveFailures,
+		TotalFailures:       st.totalFailures,
+		LastReport:          st.lastReport,
+	}
+	if !st.lastSuccess.IsZero() {
+		status.LagSeconds = time.Since(st.lastSuccess).Seconds()
+	}
+	return status
+}
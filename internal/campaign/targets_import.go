@@ -0,0 +1,143 @@
+package campaign
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	importBatchSize        = 5000 // Rows per DB insert / Redis pipeline
+	maxReportedRejectLines = 100
+	headerUserID           = "user_id" // Optional header of the first column
+)
+
+// ImportReport summarizes a CSV audience upload.
+type ImportReport struct {
+	CampaignID    int64          `json:"campaign_id,omitempty"`
+	Segment       string         `json:"segment,omitempty"`
+	Mode          string         `json:"mode"` // "replace" or "append"
+	Accepted      int            `json:"accepted"`
+	Rejected      int            `json:"rejected"`
+	RejectedLines []RejectedLine `json:"rejected_lines"` // First 100 only
+}
+
+type RejectedLine struct {
+	Line   int    `json:"line"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// importAudience streams a CSV of user IDs (first column, optional user_id header) into an audience,
+// writing to Postgres and Redis in batches. Invalid rows are skipped and reported.
+// Both stores only expose the new audience once the whole file has been read.
+func (s *Service) importAudience(ctx context.Context, a Audience, r io.Reader, replace bool) (*ImportReport, error) {
+	report := &ImportReport{Mode: "append", RejectedLines: []RejectedLine{}}
+	if replace {
+		report.Mode = "replace"
+	}
+
+	// 1. Open both imports (DB first, it is the source of truth)
+	dbImport, err := s.store.BeginTargetImport(ctx, a, replace)
+	if err != nil {
+		return nil, err
+	}
+	cacheImport, err := s.repo.BeginTargetImport(ctx, a, replace)
+	if err != nil {
+		dbImport.Abort(ctx)
+		return nil, err
+	}
+	abort := func(err error) (*ImportReport, error) {
+		dbImport.Abort(ctx)
+		cacheImport.Abort(ctx)
+		return report, err
+	}
+
+	flush := func(batch []int64) error {
+		if err := dbImport.Add(ctx, batch); err != nil {
+			return err
+		}
+		return cacheImport.Add(ctx, batch)
+	}
+
+	// 2. Stream rows
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Extra columns are allowed and ignored
+	reader.ReuseRecord = true
+
+	batch := make([]int64, 0, importBatchSize)
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return abort(fmt.Errorf("failed to read csv: %w", err))
+			}
+			report.reject(parseErr.Line, "", parseErr.Err.Error())
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := strings.TrimSpace(record[0])
+		if row == 1 {
+			value = strings.TrimSpace(strings.TrimPrefix(value, "\ufeff")) // Byte order mark of spreadsheet exports
+		}
+		uid, err := strconv.ParseInt(value, 10, 64)
+		switch {
+		case row == 1 && strings.EqualFold(value, headerUserID):
+			continue
+		case value == "":
+			report.reject(line, value, "empty user_id")
+			continue
+		case err != nil:
+			report.reject(line, value, "user_id is not an integer")
+			continue
+		case uid < 0 || uid > MaxUserID:
+			report.reject(line, value, ErrInvalidUserID.Error())
+			continue
+		}
+
+		batch = append(batch, uid)
+		report.Accepted++
+		if len(batch) == importBatchSize {
+			if err := flush(batch); err != nil {
+				return abort(err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(batch); err != nil {
+			return abort(err)
+		}
+	}
+
+	// A replace with nothing valid would silently wipe the audience
+	if report.Accepted == 0 {
+		return abort(ErrEmptyImport)
+	}
+
+	// 3. Publish
+	if err := dbImport.Commit(ctx); err != nil {
+		cacheImport.Abort(ctx)
+		return report, err
+	}
+	if err := cacheImport.Commit(ctx); err != nil {
+		return report, fmt.Errorf("targets saved to DB but cache update failed: %w", err)
+	}
+	return report, nil
+}
+
+func (r *ImportReport) reject(line int, value, reason string) {
+	r.Rejected++
+	if len(r.RejectedLines) < maxReportedRejectLines {
+		r.RejectedLines = append(r.RejectedLines, RejectedLine{Line: line, Value: value, Reason: reason})
+	}
+}
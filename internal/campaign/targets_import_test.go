@@ -0,0 +1,118 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingImport keeps what an import added and whether it was committed.
+type recordingImport struct {
+	added     []int64
+	committed bool
+}
+
+func (r *recordingImport) Add(_ context.Context, userIDs []int64) error {
+	r.added = append(r.added, userIDs...)
+	return nil
+}
+func (r *recordingImport) Commit(context.Context) error { r.committed = true; return nil }
+func (r *recordingImport) Abort(context.Context) error  { return nil }
+
+type importStore struct {
+	Store
+	imp recordingImport
+}
+
+func (s *importStore) BeginTargetImport(context.Context, Audience, bool) (TargetImport, error) {
+	return &s.imp, nil
+}
+
+type importRepository struct {
+	Repository
+	imp recordingImport
+}
+
+func (r *importRepository) BeginTargetImport(context.Context, Audience, bool) (TargetImport, error) {
+	return &r.imp, nil
+}
+
+func TestImportAudience(t *testing.T) {
+	tests := []struct {
+		name         string
+		csv          string
+		wantAdded    []int64
+		wantRejected []RejectedLine
+		wantErr      error
+	}{
+		{
+			name:      "header skipped",
+			csv:       "user_id,name\n1,a\n2,b\n",
+			wantAdded: []int64{1, 2},
+		},
+		{
+			name:      "header matched case-insensitively after a byte order mark",
+			csv:       "\ufeffUser_ID\n3\n",
+			wantAdded: []int64{3},
+		},
+		{
+			name:      "no header",
+			csv:       "4\n5\n",
+			wantAdded: []int64{4, 5},
+		},
+		{
+			name:         "malformed first row rejected",
+			csv:          "4x\n5\n",
+			wantAdded:    []int64{5},
+			wantRejected: []RejectedLine{{Line: 1, Value: "4x", Reason: "user_id is not an integer"}},
+		},
+		{
+			name:         "other header rejected",
+			csv:          "id\n6\n",
+			wantAdded:    []int64{6},
+			wantRejected: []RejectedLine{{Line: 1, Value: "id", Reason: "user_id is not an integer"}},
+		},
+		{
+			name:         "header only after the first row",
+			csv:          "7\nuser_id\n",
+			wantAdded:    []int64{7},
+			wantRejected: []RejectedLine{{Line: 2, Value: "user_id", Reason: "user_id is not an integer"}},
+		},
+		{
+			name:    "header alone",
+			csv:     "user_id\n",
+			wantErr: ErrEmptyImport,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, repo := &importStore{}, &importRepository{}
+			s := NewService(repo, store)
+
+			report, err := s.importAudience(context.Background(), SegmentAudience("vip"), strings.NewReader(tt.csv), true)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(store.imp.added, tt.wantAdded) || !reflect.DeepEqual(repo.imp.added, tt.wantAdded) {
+				t.Errorf("added = %v (DB) and %v (cache), want %v", store.imp.added, repo.imp.added, tt.wantAdded)
+			}
+			if !store.imp.committed || !repo.imp.committed {
+				t.Error("import not committed")
+			}
+			if report.Accepted != len(tt.wantAdded) || report.Rejected != len(tt.wantRejected) {
+				t.Errorf("accepted %d, rejected %d, want %d and %d", report.Accepted, report.Rejected, len(tt.wantAdded), len(tt.wantRejected))
+			}
+			if tt.wantRejected == nil {
+				tt.wantRejected = []RejectedLine{}
+			}
+			if !reflect.DeepEqual(report.RejectedLines, tt.wantRejected) {
+				t.Errorf("rejected lines = %+v, want %+v", report.RejectedLines, tt.wantRejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var ErrInvalidVariants = errors.New("invalid variants")
+
+// MaxVariants bounds the arms of one experiment.
+const MaxVariants = 10
+
+// variantIDPattern keeps IDs short enough for campaign_impressions.variant_id.
+var variantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// variantSalt decorrelates variant buckets from the sticky rotation draw of the same user and campaign.
+const variantSalt = 0x9e3779b97f4a7c15
+
+// Variant is one creative of an A/B experiment. Empty fields fall back to the campaign's own.
+type Variant struct {
+	ID        string `json:"id"`
+	Title     string `json:"title,omitempty"`
+	ImageURL  string `json:"image_url,omitempty"`
+	ActionURL string `json:"action_url,omitempty"`
+	Weight    int    `json:"weight"` // Traffic share relative to the other variants; 0 pauses the variant
+}
+
+// Variants is the experiment of a campaign; empty means the campaign has a single creative.
+type Variants []Variant
+
+// Validate checks IDs and weights, so a bad experiment fails on save rather than at request time.
+func (vs Variants) Validate() error {
+	if len(vs) == 0 {
+		return nil
+	}
+	if len(vs) > MaxVariants {
+		return fmt.Errorf("%w: at most %d variants", ErrInvalidVariants, MaxVariants)
+	}
+	seen := make(map[string]bool, len(vs))
+	total := 0
+	for i, v := range vs {
+		if !variantIDPattern.MatchString(v.ID) {
+			return fmt.Errorf("%w: variant %d: id must match %s", ErrInvalidVariants, i, variantIDPattern)
+		}
+		if seen[v.ID] {
+			return fmt.Errorf("%w: duplicate variant id %q", ErrInvalidVariants, v.ID)
+		}
+		seen[v.ID] = true
+		if v.Weight < 0 {
+			return fmt.Errorf("%w: variant %q: weight must not be negative", ErrInvalidVariants, v.ID)
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("%w: at least one variant needs a positive weight", ErrInvalidVariants)
+	}
+	return nil
+}
+
+// VariantFor assigns a user to a variant by hashing the user and campaign IDs, so the user
+// keeps the same variant on every request and pod for as long as the weights are unchanged.
+// Returns nil when the campaign has no variants.
+func (c *Campaign) VariantFor(userID int64) *Variant {
+	total := 0
+	for _, v := range c.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	bucket := int(mix64(uint64(userID)^mix64(uint64(c.ID)^variantSalt)) % uint64(total))
+	for i := range c.Variants {
+		if bucket < c.Variants[i].Weight {
+			return &c.Variants[i]
+		}
+		bucket -= c.Variants[i].Weight
+	}
+	return nil // Unreachable: bucket < total
+}
+
+// hasVariant reports whether id names one of the campaign's variants.
+func (c *Campaign) hasVariant(id string) bool {
+	for _, v := range c.Variants {
+		if v.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// withVariant returns a copy of the campaign showing the variant's creative, without the
+// other variants. The cached campaign is shared between requests and is not modified.
+func (c *Campaign) withVariant(v *Variant) *Campaign {
+	cp := *c
+	cp.Variants = nil
+	if v.Title != "" {
+		cp.Title = v.Title
+	}
+	if v.ImageURL != "" {
+		cp.ImageURL = v.ImageURL
+	}
+	if v.ActionURL != "" {
+		cp.ActionURL = v.ActionURL
+	}
+	return &cp
+}
@@ -0,0 +1,49 @@
+package campaign
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVariantFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants Variants
+		want     map[string]float64 // Expected share of users per variant ID
+	}{
+		{"no variants", nil, nil},
+		{"all paused", Variants{{ID: "a"}, {ID: "b"}}, nil},
+		{"single arm", Variants{{ID: "a", Weight: 5}}, map[string]float64{"a": 1}},
+		{"paused arm gets no users", Variants{{ID: "a", Weight: 1}, {ID: "b", Weight: 0}}, map[string]float64{"a": 1}},
+		{"even split", Variants{{ID: "a", Weight: 1}, {ID: "b", Weight: 1}}, map[string]float64{"a": 0.5, "b": 0.5}},
+		{"weighted split", Variants{{ID: "a", Weight: 1}, {ID: "b", Weight: 3}}, map[string]float64{"a": 0.25, "b": 0.75}},
+	}
+	const users = 20000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Campaign{ID: 42, Variants: tt.variants}
+			counts := map[string]int{}
+			for u := int64(1); u <= users; u++ {
+				v := c.VariantFor(u)
+				if tt.want == nil {
+					if v != nil {
+						t.Fatalf("VariantFor(%d) = %q, want nil", u, v.ID)
+					}
+					continue
+				}
+				if v == nil {
+					t.Fatalf("VariantFor(%d) = nil", u)
+				}
+				if again := c.VariantFor(u); again.ID != v.ID {
+					t.Fatalf("VariantFor(%d) changed from %q to %q", u, v.ID, again.ID)
+				}
+				counts[v.ID]++
+			}
+			for id, share := range tt.want {
+				if got := float64(counts[id]) / users; math.Abs(got-share) > 0.02 {
+					t.Errorf("variant %q got %.3f of users, want %.2f", id, got, share)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package campaign
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// periodicWorker runs a job immediately, then every interval, until its context is cancelled.
+// A failing or panicking run is logged and retried on the next tick; the loop never dies.
+// Each run is bounded by the interval so a hung Redis/DB call cannot stall the loop forever.
+type periodicWorker[R any] struct {
+	name     string // In logs and errors, e.g. "campaign sync"
+	interval time.Duration
+	job      func(ctx context.Context, now time.Time) (R, error)
+	done     func(report R, took time.Duration) // Optional, after every successful run (logging)
+
+	mu    sync.RWMutex
+	state workerState[R]
+}
+
+// workerState is the bookkeeping the workers' status reports are built from.
+type workerState[R any] struct {
+	running             bool
+	lastAttempt         time.Time
+	lastSuccess         time.Time
+	lastError           string
+	consecutiveFailures int
+	totalFailures       int
+	lastReport          R
+}
+
+// Run blocks until ctx is cancelled.
+func (w *periodicWorker[R]) Run(ctx context.Context) {
+	w.setRunning(true)
+	defer w.setRunning(false)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshot returns a copy of the current state.
+func (w *periodicWorker[R]) snapshot() workerState[R] {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.state
+}
+
+func (w *periodicWorker[R]) tick(ctx context.Context) {
+	runCtx, cancel := context.WithTimeout(ctx, w.interval)
+	defer cancel()
+
+	start := time.Now()
+	report, err := w.runOnce(runCtx, start)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.state.lastAttempt = start
+	if err != nil {
+		w.state.lastError = err.Error()
+		w.state.consecutiveFailures++
+		w.state.totalFailures++
+
+		lag := "never succeeded"
+		if !w.state.lastSuccess.IsZero() {
+			lag = time.Since(w.state.lastSuccess).Round(time.Second).String()
+		}
+		log.Printf("%s failed (%d in a row, lag %s): %v", w.name, w.state.consecutiveFailures, lag, err)
+		return
+	}
+
+	w.state.lastSuccess = start
+	w.state.lastError = ""
+	w.state.consecutiveFailures = 0
+	w.state.lastReport = report
+	if w.done != nil {
+		w.done(report, time.Since(start))
+	}
+}
+
+// runOnce turns a panic inside the job into an error so the worker keeps running.
+func (w *periodicWorker[R]) runOnce(ctx context.Context, now time.Time) (report R, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panicked: %v", w.name, r)
+		}
+	}()
+	return w.job(ctx, now)
+}
+
+func (w *periodicWorker[R]) setRunning(running bool) {
+	w.mu.Lock()
+	w.state.running = running
+	w.mu.Unlock()
+}
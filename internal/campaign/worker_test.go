@@ -0,0 +1,46 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPeriodicWorkerTick(t *testing.T) {
+	results := []func() (int, error){
+		func() (int, error) { return 1, nil },
+		func() (int, error) { return 0, errors.New("db down") },
+		func() (int, error) { panic("boom") },
+		func() (int, error) { return 4, nil },
+	}
+	var run int
+	w := periodicWorker[int]{
+		name:     "test job",
+		interval: time.Second,
+		job: func(context.Context, time.Time) (int, error) {
+			run++
+			return results[run-1]()
+		},
+	}
+
+	want := []struct {
+		lastError           string
+		consecutiveFailures int
+		totalFailures       int
+		lastReport          int
+	}{
+		{"", 0, 0, 1},
+		{"db down", 1, 1, 1},
+		{"test job panicked: boom", 2, 2, 1}, // A panic is a failure, the worker keeps running
+		{"", 0, 2, 4},
+	}
+	for i, want := range want {
+		w.tick(context.Background())
+		st := w.snapshot()
+		if st.lastError != want.lastError || st.consecutiveFailures != want.consecutiveFailures ||
+			st.totalFailures != want.totalFailures || st.lastReport != want.lastReport {
+			t.Errorf("run %d: state = %+v, want %+v", i+1, st, want)
+		}
+	}
+}
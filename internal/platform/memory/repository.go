@@ -0,0 +1,646 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"campaign-management/internal/campaign"
+)
+
+// state is an immutable view of the campaigns, swapped atomically on every refresh.
+type state struct {
+	activeIDs []int64                       // Sorted by priority, highest first
+	placed    map[string][]int64            // activeIDs split per placement, same order
+	campaigns map[int64]*campaign.Campaign  // Live campaigns, plus any saved locally since the last refresh
+	targets   map[campaign.Audience]userSet // Members per campaign whitelist / named segment
+	delivered map[int64]int64               // Impressions of budgeted campaigns in the DB at refresh
+	policy    *campaign.UserPolicy
+}
+
+type userSet map[int64]struct{}
+
+// targetPageSize bounds each campaign_targets read during a refresh.
+const targetPageSize = 10000
+
+type impressionKey struct {
+	userID     int64
+	campaignID int64
+	action     campaign.EventAction // VIEW, CLICK or DISMISS each have their own tally
+}
+
+// counters is an immutable impression tally; increments swap in a new value via CAS.
+type counters struct {
+	day, week string // Windows the Day/Week counts belong to
+	n         campaign.ImpressionCounts
+}
+
+// evicted replaces a tally that is being dropped, so a concurrent increment retries on a
+// fresh entry instead of counting into one that is no longer in the map.
+var evicted = &counters{}
+
+// activity is an immutable tally of a user's popups across campaigns, swapped like counters.
+type activity struct {
+	counters
+	last time.Time
+}
+
+// at returns the counts as seen from window w (a stale window counts as zero).
+func (c *counters) at(w campaign.CapWindows) campaign.ImpressionCounts {
+	n := c.n
+	if c.day != w.Day {
+		n.Day = 0
+	}
+	if c.week != w.Week {
+		n.Week = 0
+	}
+	return n
+}
+
+// Repository keeps campaigns in process memory (Option 2, no Redis).
+// Reads never take a lock: campaigns come from an atomically swapped snapshot and
+// impression counters live in a sync.Map of atomically swapped tallies.
+// Counters are local to this process, so frequency caps are per-pod only. Budget delivery is the
+// DB count at the last refresh plus this pod's impressions since, so other pods' impressions
+// show up one refresh late.
+type Repository struct {
+	store    campaign.Store
+	interval time.Duration
+
+	state   atomic.Pointer[state]
+	writeMu sync.Mutex // Serializes copy-on-write updates of state
+	writes  uint64     // Local writes applied to state, under writeMu
+
+	impressions sync.Map // impressionKey -> *atomic.Pointer[counters], for every action
+	delivered   sync.Map // Campaign ID -> *atomic.Int64, impressions since the last refresh
+	activity    sync.Map // User ID -> *atomic.Pointer[activity]
+	events      sync.Map // eventKey -> time.Time the claim expires
+}
+
+type eventKey struct {
+	userID  int64
+	eventID string
+}
+
+func NewRepository(store campaign.Store, interval time.Duration) *Repository {
+	r := &Repository{store: store, interval: interval}
+	r.state.Store(&state{campaigns: map[int64]*campaign.Campaign{}, targets: map[campaign.Audience]userSet{}})
+	return r
+}
+
+// refreshAttempts bounds how often Refresh reloads when local writes keep landing during the load.
+const refreshAttempts = 3
+
+// Refresh reloads all live campaigns from the Store and swaps them in. A local write (e.g. a
+// campaign removed) that lands while the Store is read may be missing from what was read, so the
+// load is discarded and retried rather than overwriting the write.
+func (r *Repository) Refresh(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		r.writeMu.Lock()
+		writes := r.writes
+		r.writeMu.Unlock()
+
+		next, taken, err := r.load(ctx)
+		if err != nil {
+			return err
+		}
+
+		r.writeMu.Lock()
+		if r.writes != writes {
+			r.writeMu.Unlock()
+			if attempt == refreshAttempts {
+				return fmt.Errorf("local writes kept landing during %d refresh attempts", attempt)
+			}
+			continue
+		}
+		r.state.Store(next)
+		r.writeMu.Unlock()
+
+		for id, n := range taken {
+			if v, ok := r.delivered.Load(id); ok {
+				v.(*atomic.Int64).Add(-n)
+			}
+		}
+		r.evictImpressions(next.campaigns)
+		r.evictActivity()
+		r.evictEvents()
+		return nil
+	}
+}
+
+// load reads the live campaigns, their targets, budget delivery and the user policy from the
+// Store. It also returns this pod's delivery counted so far, which the new DB count covers.
+func (r *Repository) load(ctx context.Context) (*state, map[int64]int64, error) {
+	list, err := r.store.ListActive(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load campaigns: %w", err)
+	}
+
+	campaigns := make(map[int64]*campaign.Campaign, len(list))
+	targets := map[campaign.Audience]userSet{}
+	var budgeted []int64
+	for _, c := range list {
+		campaigns[c.ID] = c
+		if c.Budget > 0 {
+			budgeted = append(budgeted, c.ID)
+		}
+		if c.TargetType != campaign.TargetTypeSegment {
+			continue
+		}
+		a := c.Audience()
+		if _, loaded := targets[a]; loaded {
+			continue // Named segment shared with another campaign
+		}
+		users, err := r.loadTargets(ctx, a)
+		if err != nil {
+			return nil, nil, err
+		}
+		targets[a] = users
+	}
+
+	// Local delivery up to here is covered by the DB count from now on (give or take events
+	// still buffered by the event writer), so it is subtracted once the new count is in place
+	taken := map[int64]int64{}
+	r.delivered.Range(func(k, v any) bool {
+		taken[k.(int64)] = v.(*atomic.Int64).Load()
+		return true
+	})
+	delivered, err := r.store.CountImpressions(ctx, budgeted)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load budget delivery: %w", err)
+	}
+	policy, err := r.store.GetUserPolicy(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load user policy: %w", err)
+	}
+	return newState(campaigns, state{targets: targets, delivered: delivered, policy: policy}), taken, nil
+}
+
+// Run refreshes the cache every interval until ctx is cancelled.
+func (r *Repository) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				log.Printf("memory repository refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Repository) GetActiveCampaignIDs(ctx context.Context) ([]int64, error) {
+	return r.state.Load().activeIDs, nil
+}
+
+func (r *Repository) GetCampaignsMetadata(ctx context.Context, ids []int64) (map[int64]*campaign.Campaign, error) {
+	st := r.state.Load()
+	result := make(map[int64]*campaign.Campaign, len(ids))
+	for _, id := range ids {
+		if c, ok := st.campaigns[id]; ok {
+			result[id] = c
+		}
+	}
+	return result, nil
+}
+
+func (r *Repository) IsUserTargeted(ctx context.Context, campaignID int64, userID int64) (bool, error) {
+	st := r.state.Load()
+	c, ok := st.campaigns[campaignID]
+	if !ok {
+		return false, nil
+	}
+	_, ok = st.targets[c.Audience()][userID]
+	return ok, nil
+}
+
+func (r *Repository) GetUserImpressions(ctx context.Context, userID int64, campaignIDs []int64, at time.Time) (map[int64]campaign.ImpressionCounts, error) {
+	w := campaign.WindowsAt(at)
+	result := make(map[int64]campaign.ImpressionCounts, len(campaignIDs))
+	for _, id := range campaignIDs {
+		result[id] = r.count(userID, id, campaign.ActionView, w)
+	}
+	return result, nil
+}
+
+func (r *Repository) IncrementImpression(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	r.increment(impressionKey{userID, c.ID, campaign.ActionView}, campaign.WindowsAt(at))
+	v, _ := r.delivered.LoadOrStore(c.ID, new(atomic.Int64))
+	v.(*atomic.Int64).Add(1)
+	r.touchActivity(userID, at)
+	return nil
+}
+
+func (r *Repository) IncrementClick(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	r.increment(impressionKey{userID, c.ID, campaign.ActionClick}, campaign.WindowsAt(at))
+	return nil
+}
+
+func (r *Repository) MarkDismissed(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	r.increment(impressionKey{userID, c.ID, campaign.ActionDismiss}, campaign.WindowsAt(at))
+	return nil
+}
+
+func (r *Repository) ClaimEvent(ctx context.Context, userID int64, eventID string) (bool, error) {
+	key := eventKey{userID, eventID}
+	expires := time.Now().Add(campaign.EventDedupeWindow)
+	for {
+		v, loaded := r.events.LoadOrStore(key, expires)
+		if !loaded {
+			return true, nil
+		}
+		if time.Now().Before(v.(time.Time)) {
+			return false, nil
+		}
+		// Expired claim not yet evicted: take it over unless another request just did
+		if r.events.CompareAndSwap(key, v, expires) {
+			return true, nil
+		}
+	}
+}
+
+func (r *Repository) ReleaseEvent(ctx context.Context, userID int64, eventID string) error {
+	r.events.Delete(eventKey{userID, eventID})
+	return nil
+}
+
+func (r *Repository) increment(key impressionKey, w campaign.CapWindows) {
+	for {
+		v, _ := r.impressions.LoadOrStore(key, &atomic.Pointer[counters]{})
+		if r.incrementTally(v.(*atomic.Pointer[counters]), w) {
+			return
+		}
+		r.impressions.CompareAndDelete(key, v) // Finish the eviction, then start over
+	}
+}
+
+// incrementTally counts one event into ptr, or returns false if the tally was evicted.
+func (r *Repository) incrementTally(ptr *atomic.Pointer[counters], w campaign.CapWindows) bool {
+	for {
+		old := ptr.Load()
+		if old == evicted {
+			return false
+		}
+		next := &counters{day: w.Day, week: w.Week}
+		if old != nil {
+			next.n = old.at(w)
+		}
+		next.n.Day++
+		next.n.Week++
+		next.n.Lifetime++
+		if ptr.CompareAndSwap(old, next) {
+			return true
+		}
+	}
+}
+
+// touchActivity counts a popup seen by the user at time at.
+func (r *Repository) touchActivity(userID int64, at time.Time) {
+	w := campaign.WindowsAt(at)
+	v, _ := r.activity.LoadOrStore(userID, &atomic.Pointer[activity]{})
+	ptr := v.(*atomic.Pointer[activity])
+
+	for {
+		old := ptr.Load()
+		next := &activity{counters: counters{day: w.Day, week: w.Week}, last: at}
+		if old != nil {
+			next.n = old.at(w)
+			if old.last.After(at) {
+				next.last = old.last
+			}
+		}
+		next.n.Day++
+		next.n.Week++
+		next.n.Lifetime++
+		if ptr.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+func (r *Repository) GetEvaluationSnapshot(ctx context.Context, userID int64, placement string, at time.Time) (*campaign.EvaluationSnapshot, error) {
+	w := campaign.WindowsAt(at)
+	st := r.state.Load()
+	ids := st.placed[placement]
+	snap := &campaign.EvaluationSnapshot{
+		ActiveIDs:   ids,
+		Campaigns:   st.campaigns,
+		Targeted:    make(map[int64]bool, len(ids)),
+		Impressions: make(map[int64]campaign.ImpressionCounts, len(ids)),
+		Clicks:      make(map[int64]campaign.ImpressionCounts, len(ids)),
+		Dismissed:   make(map[int64]bool, len(ids)),
+		Delivered:   make(map[int64]int64, len(ids)),
+		Policy:      st.policy,
+	}
+	if v, ok := r.activity.Load(userID); ok {
+		if a := v.(*atomic.Pointer[activity]).Load(); a != nil {
+			n := a.at(w)
+			snap.Activity = campaign.UserActivity{LastSeen: a.last, Day: n.Day, Week: n.Week}
+		}
+	}
+	for _, id := range ids {
+		if c, ok := st.campaigns[id]; ok {
+			_, snap.Targeted[id] = st.targets[c.Audience()][userID]
+		}
+		snap.Impressions[id] = r.count(userID, id, campaign.ActionView, w)
+		snap.Clicks[id] = r.count(userID, id, campaign.ActionClick, w)
+		snap.Dismissed[id] = r.count(userID, id, campaign.ActionDismiss, w).Lifetime > 0
+		snap.Delivered[id] = st.delivered[id]
+		if v, ok := r.delivered.Load(id); ok {
+			snap.Delivered[id] += v.(*atomic.Int64).Load()
+		}
+	}
+	return snap, nil
+}
+
+// SaveCampaign applies an admin write locally so this pod sees it before the next refresh.
+func (r *Repository) SaveCampaign(ctx context.Context, c *campaign.Campaign) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	st := r.state.Load()
+	campaigns := r.copyCampaigns()
+	cp := *c
+	campaigns[c.ID] = &cp
+	r.state.Store(newState(campaigns, *st))
+	r.writes++
+	return nil
+}
+
+func (r *Repository) RemoveCampaign(ctx context.Context, id int64) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	st := r.state.Load()
+	campaigns := r.copyCampaigns()
+	delete(campaigns, id)
+	r.state.Store(newState(campaigns, *st))
+	r.writes++
+	return nil
+}
+
+// SaveUserPolicy applies an admin write locally so this pod sees it before the next refresh.
+func (r *Repository) SaveUserPolicy(ctx context.Context, p *campaign.UserPolicy) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	next := *r.state.Load()
+	cp := *p
+	next.policy = &cp
+	r.state.Store(&next)
+	r.writes++
+	return nil
+}
+
+// RestoreDelivered is a no-op: every refresh counts the delivered impressions in the DB.
+func (r *Repository) RestoreDelivered(ctx context.Context, c *campaign.Campaign, count int64, at time.Time) error {
+	return nil
+}
+
+func (r *Repository) GetCacheState(ctx context.Context) (*campaign.CacheState, error) {
+	st := r.state.Load()
+	state := &campaign.CacheState{
+		Metadata:   make(map[int64]*campaign.Campaign, len(st.campaigns)),
+		Active:     make(map[int64]int, len(st.activeIDs)),
+		Placements: make(map[int64][]string, len(st.activeIDs)),
+		Audiences:  make(map[campaign.Audience]bool, len(st.targets)),
+	}
+	for a := range st.targets {
+		state.Audiences[a] = true
+	}
+	for id, c := range st.campaigns {
+		state.Metadata[id] = c
+	}
+	for _, id := range st.activeIDs {
+		state.Active[id] = st.campaigns[id].Priority
+	}
+	for p, ids := range st.placed {
+		for _, id := range ids {
+			state.Placements[id] = append(state.Placements[id], p)
+		}
+	}
+	return state, nil
+}
+
+func (r *Repository) AddTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	r.updateTargets(a, false, func(users userSet) {
+		for _, uid := range userIDs {
+			users[uid] = struct{}{}
+		}
+	})
+	return nil
+}
+
+func (r *Repository) RemoveTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	r.updateTargets(a, false, func(users userSet) {
+		for _, uid := range userIDs {
+			delete(users, uid)
+		}
+	})
+	return nil
+}
+
+func (r *Repository) ReplaceTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	r.updateTargets(a, true, func(users userSet) {
+		for _, uid := range userIDs {
+			users[uid] = struct{}{}
+		}
+	})
+	return nil
+}
+
+// targetImport collects users locally and merges them in on Commit.
+type targetImport struct {
+	r        *Repository
+	audience campaign.Audience
+	replace  bool
+	users    []int64
+}
+
+func (r *Repository) BeginTargetImport(ctx context.Context, a campaign.Audience, replace bool) (campaign.TargetImport, error) {
+	return &targetImport{r: r, audience: a, replace: replace}, nil
+}
+
+func (t *targetImport) Add(ctx context.Context, userIDs []int64) error {
+	t.users = append(t.users, userIDs...)
+	return nil
+}
+
+func (t *targetImport) Commit(ctx context.Context) error {
+	t.r.updateTargets(t.audience, t.replace, func(users userSet) {
+		for _, uid := range t.users {
+			users[uid] = struct{}{}
+		}
+	})
+	t.users = nil
+	return nil
+}
+
+func (t *targetImport) Abort(ctx context.Context) error {
+	t.users = nil
+	return nil
+}
+
+// updateTargets copies one audience's user set (or starts empty when reset), applies fn and swaps it in.
+func (r *Repository) updateTargets(a campaign.Audience, reset bool, fn func(userSet)) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	st := r.state.Load()
+	users := userSet{}
+	if !reset {
+		for uid := range st.targets[a] {
+			users[uid] = struct{}{}
+		}
+	}
+	fn(users)
+
+	targets := make(map[campaign.Audience]userSet, len(st.targets)+1)
+	for id, set := range st.targets {
+		targets[id] = set
+	}
+	targets[a] = users
+	next := *st
+	next.targets = targets
+	r.state.Store(&next)
+	r.writes++
+}
+
+// loadTargets pages through the membership table of one audience.
+func (r *Repository) loadTargets(ctx context.Context, a campaign.Audience) (userSet, error) {
+	users := userSet{}
+	after := int64(-1)
+	for {
+		page, err := r.store.ListTargets(ctx, a, after, targetPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load targets of %+v: %w", a, err)
+		}
+		for _, uid := range page {
+			users[uid] = struct{}{}
+		}
+		if len(page) < targetPageSize {
+			return users, nil
+		}
+		after = page[len(page)-1]
+	}
+}
+
+// copyCampaigns must be called with writeMu held.
+func (r *Repository) copyCampaigns() map[int64]*campaign.Campaign {
+	cur := r.state.Load().campaigns
+	campaigns := make(map[int64]*campaign.Campaign, len(cur)+1)
+	for id, c := range cur {
+		campaigns[id] = c
+	}
+	return campaigns
+}
+
+func (r *Repository) count(userID, campaignID int64, action campaign.EventAction, w campaign.CapWindows) campaign.ImpressionCounts {
+	v, ok := r.impressions.Load(impressionKey{userID, campaignID, action})
+	if !ok {
+		return campaign.ImpressionCounts{}
+	}
+	c := v.(*atomic.Pointer[counters]).Load()
+	if c == nil {
+		return campaign.ImpressionCounts{}
+	}
+	return c.at(w)
+}
+
+// evictImpressions drops counters of campaigns that no longer exist, and counters whose day
+// and week windows have passed unless the campaign still needs the lifetime count.
+func (r *Repository) evictImpressions(campaigns map[int64]*campaign.Campaign) {
+	w := campaign.WindowsAt(time.Now())
+	r.impressions.Range(func(k, v any) bool {
+		key, ptr := k.(impressionKey), v.(*atomic.Pointer[counters])
+		old := ptr.Load()
+		if old == nil || old == evicted {
+			return true // Being created or already on its way out
+		}
+		if c, ok := campaigns[key.campaignID]; ok && (old.week == w.Week || lifetimeCounted(c, key.action)) {
+			return true
+		}
+		if ptr.CompareAndSwap(old, evicted) { // Fails if an increment got in first: keep it
+			r.impressions.CompareAndDelete(k, v)
+		}
+		return true
+	})
+	r.delivered.Range(func(k, _ any) bool {
+		if _, ok := campaigns[k.(int64)]; !ok {
+			r.delivered.Delete(k)
+		}
+		return true
+	})
+}
+
+// lifetimeCounted reports whether the campaign limits action over the user's lifetime, so its
+// tally must outlive the day and week windows.
+func lifetimeCounted(c *campaign.Campaign, action campaign.EventAction) bool {
+	switch action {
+	case campaign.ActionView:
+		return c.Cap().Lifetime > 0
+	case campaign.ActionClick:
+		return c.ClickCap.Lifetime > 0
+	case campaign.ActionDismiss:
+		return c.DismissHides
+	}
+	return true
+}
+
+// evictActivity drops users who have not seen a popup for longer than any policy window.
+func (r *Repository) evictActivity() {
+	cutoff := time.Now().Add(-campaign.UserActivityTTL)
+	r.activity.Range(func(k, v any) bool {
+		if a := v.(*atomic.Pointer[activity]).Load(); a == nil || a.last.Before(cutoff) {
+			r.activity.Delete(k)
+		}
+		return true
+	})
+}
+
+// evictEvents drops expired event ID claims.
+func (r *Repository) evictEvents() {
+	now := time.Now()
+	r.events.Range(func(k, v any) bool {
+		if now.After(v.(time.Time)) {
+			r.events.CompareAndDelete(k, v)
+		}
+		return true
+	})
+}
+
+// newState builds the active lists ordered by priority desc, ties broken by numeric id desc. Redis
+// orders tied ZSET members as strings ("9" before "10"), so without rotation ties may be served
+// in a different order there. The other fields are taken from base.
+func newState(campaigns map[int64]*campaign.Campaign, base state) *state {
+	active := make([]*campaign.Campaign, 0, len(campaigns))
+	for _, c := range campaigns {
+		if c.IsActive {
+			active = append(active, c)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		if active[i].Priority != active[j].Priority {
+			return active[i].Priority > active[j].Priority
+		}
+		return active[i].ID > active[j].ID
+	})
+
+	ids := make([]int64, len(active))
+	placed := map[string][]int64{}
+	for i, c := range active {
+		ids[i] = c.ID
+		p := c.PlacementName()
+		placed[p] = append(placed[p], c.ID)
+	}
+	base.activeIDs, base.placed, base.campaigns = ids, placed, campaigns
+	return &base
+}
@@ -0,0 +1,184 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"campaign-management/internal/campaign"
+
+	"github.com/lib/pq"
+)
+
+// metricsSources reads hours before the rollup watermark from the hourly rollups and the
+// unaggregated tail from campaign_impressions. It defines two relations keyed by "at" and
+// split by variant: counts (impressions/clicks/dismisses) and viewers (one row per VIEW user, for reach).
+// Expects the range as $1, $2 and the campaign IDs (empty = all) as $3.
+const metricsSources = `
+	WITH wm AS (
+		SELECT COALESCE((SELECT watermark FROM rollup_state WHERE name = '` + rollupName + `'), '-infinity'::timestamptz) AS w
+	),
+	counts AS (
+		SELECT m.campaign_id, m.variant_id, m.hour AS at, m.impressions, m.clicks, m.dismisses
+		FROM campaign_metrics_hourly m, wm
+		WHERE m.hour >= $1 AND m.hour < LEAST($2, wm.w)
+			AND (COALESCE(cardinality($3::bigint[]), 0) = 0 OR m.campaign_id = ANY($3))
+		UNION ALL
+		SELECT i.campaign_id, i.variant_id, i.created_at,
+			(i.action = 'VIEW')::int, (i.action = 'CLICK')::int, (i.action = 'DISMISS')::int
+		FROM campaign_impressions i, wm
+		WHERE i.created_at >= GREATEST($1, wm.w) AND i.created_at < $2
+			AND (COALESCE(cardinality($3::bigint[]), 0) = 0 OR i.campaign_id = ANY($3))
+	),
+	viewers AS (
+		SELECT v.campaign_id, v.variant_id, v.hour AS at, v.user_id
+		FROM campaign_viewers_hourly v, wm
+		WHERE v.hour >= $1 AND v.hour < LEAST($2, wm.w)
+			AND (COALESCE(cardinality($3::bigint[]), 0) = 0 OR v.campaign_id = ANY($3))
+		UNION ALL
+		SELECT i.campaign_id, i.variant_id, i.created_at, i.user_id
+		FROM campaign_impressions i, wm
+		WHERE i.action = 'VIEW' AND i.created_at >= GREATEST($1, wm.w) AND i.created_at < $2
+			AND (COALESCE(cardinality($3::bigint[]), 0) = 0 OR i.campaign_id = ANY($3))
+	)`
+
+// bucketExpr truncates "at" to the query granularity ($4) in its time zone ($5).
+// Rollups are per UTC hour; AnalyticsQuery rejects time zones whose buckets do not start on one.
+const bucketExpr = `date_trunc($4, at AT TIME ZONE $5) AT TIME ZONE $5`
+
+// MetricsSeries aggregates events per campaign and hour/day bucket, in the query's time zone.
+func (s *Store) MetricsSeries(ctx context.Context, q campaign.AnalyticsQuery) ([]campaign.MetricsRow, error) {
+	query := metricsSources + `
+		SELECT c.campaign_id, c.bucket, c.impressions, COALESCE(v.reach, 0), c.clicks, c.dismisses
+		FROM (
+			SELECT campaign_id, ` + bucketExpr + ` AS bucket,
+				SUM(impressions) AS impressions, SUM(clicks) AS clicks, SUM(dismisses) AS dismisses
+			FROM counts GROUP BY 1, 2
+		) c
+		LEFT JOIN (
+			SELECT campaign_id, ` + bucketExpr + ` AS bucket, COUNT(DISTINCT user_id) AS reach
+			FROM viewers GROUP BY 1, 2
+		) v USING (campaign_id, bucket)
+		ORDER BY c.campaign_id, c.bucket
+	`
+	rows, err := s.db.QueryContext(ctx, query, q.From, q.To, pq.Array(q.CampaignIDs), string(q.Granularity), q.Location.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics series: %w", err)
+	}
+	defer rows.Close()
+
+	var result []campaign.MetricsRow
+	for rows.Next() {
+		var row campaign.MetricsRow
+		if err := rows.Scan(append([]any{&row.CampaignID, &row.Bucket}, scanMetrics(&row.Metrics)...)...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// MetricsTotals aggregates events per campaign over the whole range (reach is distinct over the range).
+func (s *Store) MetricsTotals(ctx context.Context, q campaign.AnalyticsQuery) ([]campaign.MetricsRow, error) {
+	query := metricsSources + `
+		SELECT c.campaign_id, c.impressions, COALESCE(v.reach, 0), c.clicks, c.dismisses
+		FROM (
+			SELECT campaign_id, SUM(impressions) AS impressions, SUM(clicks) AS clicks, SUM(dismisses) AS dismisses
+			FROM counts GROUP BY 1
+		) c
+		LEFT JOIN (
+			SELECT campaign_id, COUNT(DISTINCT user_id) AS reach
+			FROM viewers GROUP BY 1
+		) v USING (campaign_id)
+		ORDER BY c.campaign_id
+	`
+	rows, err := s.db.QueryContext(ctx, query, q.From, q.To, pq.Array(q.CampaignIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metrics totals: %w", err)
+	}
+	defer rows.Close()
+
+	var result []campaign.MetricsRow
+	for rows.Next() {
+		var row campaign.MetricsRow
+		if err := rows.Scan(append([]any{&row.CampaignID}, scanMetrics(&row.Metrics)...)...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// MetricsVariantTotals aggregates events per campaign and A/B variant over the whole range.
+// Events of campaigns without variants come back with an empty VariantID.
+func (s *Store) MetricsVariantTotals(ctx context.Context, q campaign.AnalyticsQuery) ([]campaign.MetricsRow, error) {
+	query := metricsSources + `
+		SELECT c.campaign_id, c.variant_id, c.impressions, COALESCE(v.reach, 0), c.clicks, c.dismisses
+		FROM (
+			SELECT campaign_id, variant_id, SUM(impressions) AS impressions, SUM(clicks) AS clicks, SUM(dismisses) AS dismisses
+			FROM counts GROUP BY 1, 2
+		) c
+		LEFT JOIN (
+			SELECT campaign_id, variant_id, COUNT(DISTINCT user_id) AS reach
+			FROM viewers GROUP BY 1, 2
+		) v USING (campaign_id, variant_id)
+		ORDER BY c.campaign_id, c.variant_id
+	`
+	rows, err := s.db.QueryContext(ctx, query, q.From, q.To, pq.Array(q.CampaignIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query variant metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var result []campaign.MetricsRow
+	for rows.Next() {
+		var row campaign.MetricsRow
+		if err := rows.Scan(append([]any{&row.CampaignID, &row.VariantID}, scanMetrics(&row.Metrics)...)...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// CountImpressions totals the VIEWs of each campaign across all users: rolled-up hours before the
+// watermark plus the raw tail. Late events below the watermark count once their hour is rebuilt.
+func (s *Store) CountImpressions(ctx context.Context, campaignIDs []int64) (map[int64]int64, error) {
+	result := make(map[int64]int64, len(campaignIDs))
+	if len(campaignIDs) == 0 {
+		return result, nil
+	}
+	query := `
+		WITH wm AS (
+			SELECT COALESCE((SELECT watermark FROM rollup_state WHERE name = '` + rollupName + `'), '-infinity'::timestamptz) AS w
+		)
+		SELECT campaign_id, SUM(n)::bigint FROM (
+			SELECT m.campaign_id, m.impressions AS n
+			FROM campaign_metrics_hourly m, wm
+			WHERE m.campaign_id = ANY($1) AND m.hour < wm.w
+			UNION ALL
+			SELECT i.campaign_id, 1
+			FROM campaign_impressions i, wm
+			WHERE i.campaign_id = ANY($1) AND i.action = 'VIEW' AND i.created_at >= wm.w
+		) t
+		GROUP BY campaign_id
+	`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(campaignIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count impressions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		result[id] = n
+	}
+	return result, rows.Err()
+}
+
+// scanMetrics matches the column order impressions, reach, clicks, dismisses.
+func scanMetrics(m *campaign.Metrics) []any {
+	return []any{&m.Impressions, &m.UniqueReach, &m.Clicks, &m.Dismisses}
+}
@@ -0,0 +1,334 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"campaign-management/internal/campaign"
+
+	"github.com/lib/pq"
+)
+
+// Repository serves the hot path straight from PostgreSQL (Option 1, no Redis).
+// The campaigns table is the source of truth, so the write methods used for syncing are no-ops.
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// GetActiveCampaignIDs orders like the Redis ZSET: priority desc, ties broken by id desc
+// (numerically here, as strings in Redis).
+func (r *Repository) GetActiveCampaignIDs(ctx context.Context) ([]int64, error) {
+	query := `SELECT id FROM campaigns WHERE is_active ORDER BY priority DESC, id DESC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *Repository) GetCampaignsMetadata(ctx context.Context, ids []int64) (map[int64]*campaign.Campaign, error) {
+	query := `SELECT ` + campaignColumns + ` FROM campaigns WHERE id = ANY($1)`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaigns metadata: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]*campaign.Campaign, len(ids))
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[c.ID] = c
+	}
+	return result, rows.Err()
+}
+
+// targetedExpr resolves membership through the campaign's named segment, or its own whitelist.
+// Expects the campaigns table in scope and the user ID as $1.
+const targetedExpr = `
+	CASE WHEN COALESCE(campaigns.target_segment, '') <> ''
+		THEN EXISTS (SELECT 1 FROM segment_members m WHERE m.segment_name = campaigns.target_segment AND m.user_id = $1)
+		ELSE EXISTS (SELECT 1 FROM campaign_targets t WHERE t.campaign_id = campaigns.id AND t.user_id = $1)
+	END`
+
+// IsUserTargeted checks the campaign's segment_members or campaign_targets.
+func (r *Repository) IsUserTargeted(ctx context.Context, campaignID int64, userID int64) (bool, error) {
+	query := `SELECT ` + targetedExpr + ` FROM campaigns WHERE id = $2`
+	var ok bool
+	err := r.db.QueryRowContext(ctx, query, userID, campaignID).Scan(&ok)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// GetUserImpressions counts VIEW rows in campaign_impressions, per cap window.
+func (r *Repository) GetUserImpressions(ctx context.Context, userID int64, campaignIDs []int64, at time.Time) (map[int64]campaign.ImpressionCounts, error) {
+	w := campaign.WindowsAt(at)
+	query := `
+		SELECT campaign_id,
+			COUNT(*) FILTER (WHERE created_at >= $3),
+			COUNT(*) FILTER (WHERE created_at >= $4),
+			COUNT(*)
+		FROM campaign_impressions
+		WHERE user_id = $1 AND campaign_id = ANY($2) AND action = 'VIEW'
+		GROUP BY campaign_id
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, pq.Array(campaignIDs), w.DayStart, w.WeekStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int64]campaign.ImpressionCounts, len(campaignIDs))
+	for _, id := range campaignIDs {
+		result[id] = campaign.ImpressionCounts{}
+	}
+	for rows.Next() {
+		var id int64
+		var n campaign.ImpressionCounts
+		if err := rows.Scan(&id, &n.Day, &n.Week, &n.Lifetime); err != nil {
+			return nil, err
+		}
+		result[id] = n
+	}
+	return result, rows.Err()
+}
+
+// IncrementImpression is a no-op: the event log writes the VIEW row that is counted.
+// The event log must therefore be written synchronously (see cmd/api), or caps lag behind it.
+func (r *Repository) IncrementImpression(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	return nil
+}
+
+// IncrementClick is a no-op: the event log writes the CLICK row that is counted.
+func (r *Repository) IncrementClick(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	return nil
+}
+
+// MarkDismissed is a no-op: the event log writes the DISMISS row that is checked.
+func (r *Repository) MarkDismissed(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	return nil
+}
+
+// GetEvaluationSnapshot loads the placement's active campaigns with the user's targeting,
+// impression/click counts and dismissals in one query, plus budget delivery and the user
+// policy with the user's activity if needed.
+func (r *Repository) GetEvaluationSnapshot(ctx context.Context, userID int64, placement string, at time.Time) (*campaign.EvaluationSnapshot, error) {
+	w := campaign.WindowsAt(at)
+	query := `
+		SELECT ` + campaignColumns + `,
+			` + targetedExpr + `,
+			ev.day, ev.week, ev.lifetime,
+			ev.click_day, ev.click_week, ev.click_lifetime,
+			ev.dismissed
+		FROM campaigns
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) FILTER (WHERE i.action = 'VIEW' AND i.created_at >= $2) AS day,
+				COUNT(*) FILTER (WHERE i.action = 'VIEW' AND i.created_at >= $3) AS week,
+				COUNT(*) FILTER (WHERE i.action = 'VIEW') AS lifetime,
+				COUNT(*) FILTER (WHERE i.action = 'CLICK' AND i.created_at >= $2) AS click_day,
+				COUNT(*) FILTER (WHERE i.action = 'CLICK' AND i.created_at >= $3) AS click_week,
+				COUNT(*) FILTER (WHERE i.action = 'CLICK') AS click_lifetime,
+				BOOL_OR(i.action = 'DISMISS') IS TRUE AS dismissed
+			FROM campaign_impressions i
+			WHERE i.campaign_id = campaigns.id AND i.user_id = $1
+		) ev
+		WHERE is_active AND placement = $4
+		ORDER BY priority DESC, id DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, w.DayStart, w.WeekStart, placement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load evaluation snapshot: %w", err)
+	}
+	defer rows.Close()
+
+	snap := &campaign.EvaluationSnapshot{
+		Campaigns:   map[int64]*campaign.Campaign{},
+		Targeted:    map[int64]bool{},
+		Impressions: map[int64]campaign.ImpressionCounts{},
+		Clicks:      map[int64]campaign.ImpressionCounts{},
+		Dismissed:   map[int64]bool{},
+		Delivered:   map[int64]int64{},
+	}
+	var budgeted []int64
+	for rows.Next() {
+		var targeted, dismissed bool
+		var seen, clicked campaign.ImpressionCounts
+		c, err := scanCampaign(rows, &targeted,
+			&seen.Day, &seen.Week, &seen.Lifetime,
+			&clicked.Day, &clicked.Week, &clicked.Lifetime,
+			&dismissed)
+		if err != nil {
+			return nil, err
+		}
+		snap.ActiveIDs = append(snap.ActiveIDs, c.ID)
+		snap.Campaigns[c.ID] = c
+		snap.Targeted[c.ID] = targeted
+		snap.Impressions[c.ID] = seen
+		snap.Clicks[c.ID] = clicked
+		snap.Dismissed[c.ID] = dismissed
+		if c.Budget > 0 {
+			budgeted = append(budgeted, c.ID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Campaign-wide delivery is a second query, only paid when a budget is in play
+	if len(budgeted) > 0 {
+		delivered, err := NewStore(r.db).CountImpressions(ctx, budgeted)
+		if err != nil {
+			return nil, err
+		}
+		snap.Delivered = delivered
+	}
+
+	policy, err := NewStore(r.db).GetUserPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		snap.Policy = policy
+		if snap.Activity, err = r.userActivity(ctx, userID, at); err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+// userActivity counts the user's VIEW rows across campaigns. Only rows inside
+// UserActivityTTL matter, which also covers the weekly window.
+func (r *Repository) userActivity(ctx context.Context, userID int64, at time.Time) (campaign.UserActivity, error) {
+	w := campaign.WindowsAt(at)
+	query := `
+		SELECT MAX(created_at),
+			COUNT(*) FILTER (WHERE created_at >= $3),
+			COUNT(*) FILTER (WHERE created_at >= $4)
+		FROM campaign_impressions
+		WHERE user_id = $1 AND action = 'VIEW' AND created_at >= $2
+	`
+	var a campaign.UserActivity
+	var last sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, userID, at.Add(-campaign.UserActivityTTL), w.DayStart, w.WeekStart).
+		Scan(&last, &a.Day, &a.Week)
+	if err != nil {
+		return a, fmt.Errorf("failed to load user activity: %w", err)
+	}
+	a.LastSeen = last.Time
+	return a, nil
+}
+
+// ClaimEvent inserts the claim into event_claims. Client event IDs are also deduped by the
+// unique (user_id, event_id) index of campaign_impressions, but a replayed serve token is not.
+// The user's expired claims are purged first, so the table only holds live ones.
+func (r *Repository) ClaimEvent(ctx context.Context, userID int64, eventID string) (bool, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM event_claims WHERE user_id = $1 AND expires_at <= NOW()`, userID); err != nil {
+		return false, err
+	}
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO event_claims (user_id, claim, expires_at) VALUES ($1, $2, NOW() + make_interval(secs => $3))
+		ON CONFLICT (user_id, claim) DO NOTHING
+	`, userID, eventID, campaign.EventDedupeWindow.Seconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *Repository) ReleaseEvent(ctx context.Context, userID int64, eventID string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM event_claims WHERE user_id = $1 AND claim = $2`, userID, eventID)
+	return err
+}
+
+// SaveCampaign is a no-op: the Store has already written the row.
+func (r *Repository) SaveCampaign(ctx context.Context, c *campaign.Campaign) error {
+	return nil
+}
+
+// SaveUserPolicy is a no-op: the Store has already written the row.
+func (r *Repository) SaveUserPolicy(ctx context.Context, p *campaign.UserPolicy) error {
+	return nil
+}
+
+// RestoreDelivered is a no-op: delivered impressions are counted in the DB.
+func (r *Repository) RestoreDelivered(ctx context.Context, c *campaign.Campaign, count int64, at time.Time) error {
+	return nil
+}
+
+// RemoveCampaign is a no-op: the Store has already deleted the row.
+func (r *Repository) RemoveCampaign(ctx context.Context, id int64) error {
+	return nil
+}
+
+// AddTargetUsers is a no-op: membership tables are read directly.
+func (r *Repository) AddTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	return nil
+}
+
+// RemoveTargetUsers is a no-op: membership tables are read directly.
+func (r *Repository) RemoveTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	return nil
+}
+
+// ReplaceTargetUsers is a no-op: membership tables are read directly.
+func (r *Repository) ReplaceTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	return nil
+}
+
+// BeginTargetImport returns a no-op import: membership tables are read directly.
+func (r *Repository) BeginTargetImport(ctx context.Context, a campaign.Audience, replace bool) (campaign.TargetImport, error) {
+	return noopImport{}, nil
+}
+
+type noopImport struct{}
+
+func (noopImport) Add(ctx context.Context, userIDs []int64) error { return nil }
+func (noopImport) Commit(ctx context.Context) error               { return nil }
+func (noopImport) Abort(ctx context.Context) error                { return nil }
+
+// GetCacheState reports the live campaigns themselves: there is no separate cache to drift.
+func (r *Repository) GetCacheState(ctx context.Context) (*campaign.CacheState, error) {
+	list, err := NewStore(r.db).ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state := &campaign.CacheState{
+		Metadata:   make(map[int64]*campaign.Campaign, len(list)),
+		Active:     make(map[int64]int, len(list)),
+		Placements: make(map[int64][]string, len(list)),
+		Audiences:  map[campaign.Audience]bool{},
+	}
+	for _, c := range list {
+		state.Metadata[c.ID] = c
+		state.Active[c.ID] = c.Priority
+		state.Placements[c.ID] = []string{c.PlacementName()}
+		if c.TargetType == campaign.TargetTypeSegment {
+			state.Audiences[c.Audience()] = true // Read from the membership tables
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"campaign-management/internal/campaign"
+
+	"github.com/lib/pq"
+)
+
+const (
+	rollupName = "campaign_metrics"
+
+	// rollupMaxHours bounds a single run, so a backfill of old data proceeds in steps.
+	rollupMaxHours = 7 * 24
+
+	// rollupIngestMargin covers inserts still in flight: inserted_at is the inserting
+	// transaction's start, which may commit a little after a later transaction reads.
+	rollupIngestMargin = time.Minute
+)
+
+// RollupEvents rebuilds whole UTC hours of campaign_metrics_hourly / campaign_viewers_hourly
+// (per campaign and variant) from campaign_impressions, in one transaction:
+//   - new hours, from the watermark up to the last complete hour before upTo;
+//   - already rolled-up hours that received rows since the last run (late events).
+//
+// Rebuilding deletes and re-inserts the hour, so reruns are idempotent. The rollup_state row
+// is locked for the duration, so concurrent runs from other pods wait and then find nothing to do.
+func (s *Store) RollupEvents(ctx context.Context, upTo time.Time) (*campaign.RollupReport, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 1. Lock the state row
+	if _, err := tx.ExecContext(ctx, `INSERT INTO rollup_state (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, rollupName); err != nil {
+		return nil, fmt.Errorf("failed to init rollup state: %w", err)
+	}
+	var watermark, ingested sql.NullTime
+	var ingestUntil time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT watermark, ingested_until, NOW() - make_interval(secs => $2)
+		FROM rollup_state WHERE name = $1 FOR UPDATE
+	`, rollupName, rollupIngestMargin.Seconds()).Scan(&watermark, &ingested, &ingestUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock rollup state: %w", err)
+	}
+
+	// 2. First run: start from the oldest event
+	if !watermark.Valid {
+		var oldest sql.NullTime
+		if err := tx.QueryRowContext(ctx, `SELECT MIN(created_at) FROM campaign_impressions`).Scan(&oldest); err != nil {
+			return nil, err
+		}
+		if !oldest.Valid {
+			oldest.Time = upTo // No events yet
+		}
+		watermark = sql.NullTime{Time: oldest.Time.UTC().Truncate(time.Hour), Valid: true}
+		ingested = sql.NullTime{Time: ingestUntil, Valid: true} // Everything so far is >= watermark
+	}
+	from := watermark.Time.UTC()
+	to := upTo.UTC().Truncate(time.Hour)
+	if limit := from.Add(rollupMaxHours * time.Hour); to.After(limit) {
+		to = limit
+	}
+
+	// 3. Hours to (re)build
+	var hours []string
+	for h := from; h.Before(to); h = h.Add(time.Hour) {
+		hours = append(hours, h.Format(time.RFC3339))
+	}
+	report := &campaign.RollupReport{Watermark: from, NewHours: len(hours)}
+	if to.After(from) {
+		report.Watermark = to
+	}
+
+	if ingested.Valid && ingestUntil.After(ingested.Time) {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT DISTINCT date_trunc('hour', created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+			FROM campaign_impressions
+			WHERE inserted_at > $1 AND inserted_at <= $2 AND created_at < $3
+		`, ingested.Time, ingestUntil, from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find late events: %w", err)
+		}
+		for rows.Next() {
+			var h time.Time
+			if err := rows.Scan(&h); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			hours = append(hours, h.UTC().Format(time.RFC3339))
+			report.LateHours++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. Rebuild
+	if len(hours) > 0 {
+		if err := rebuildHours(ctx, tx, hours); err != nil {
+			return nil, err
+		}
+	}
+
+	// 5. Advance
+	if ingested.Valid && ingested.Time.After(ingestUntil) {
+		ingestUntil = ingested.Time // Never move backwards
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE rollup_state SET watermark = $2, ingested_until = $3 WHERE name = $1`,
+		rollupName, report.Watermark, ingestUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to advance rollup state: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// rebuildHours replaces the rollup rows of the given UTC hours (RFC 3339).
+func rebuildHours(ctx context.Context, tx *sql.Tx, hours []string) error {
+	arg := pq.Array(hours) // pq.Array has no timestamp encoding, send RFC 3339 text
+	stmts := []string{
+		`DELETE FROM campaign_metrics_hourly WHERE hour = ANY($1::timestamptz[])`,
+		`DELETE FROM campaign_viewers_hourly WHERE hour = ANY($1::timestamptz[])`,
+		`INSERT INTO campaign_metrics_hourly (campaign_id, variant_id, hour, impressions, clicks, dismisses)
+		SELECT i.campaign_id, i.variant_id, h.hour,
+			COUNT(*) FILTER (WHERE i.action = 'VIEW'),
+			COUNT(*) FILTER (WHERE i.action = 'CLICK'),
+			COUNT(*) FILTER (WHERE i.action = 'DISMISS')
+		FROM unnest($1::timestamptz[]) AS h(hour)
+		JOIN campaign_impressions i ON i.created_at >= h.hour AND i.created_at < h.hour + interval '1 hour'
+		GROUP BY i.campaign_id, i.variant_id, h.hour`,
+		`INSERT INTO campaign_viewers_hourly (campaign_id, variant_id, hour, user_id)
+		SELECT DISTINCT i.campaign_id, i.variant_id, h.hour, i.user_id
+		FROM unnest($1::timestamptz[]) AS h(hour)
+		JOIN campaign_impressions i ON i.created_at >= h.hour AND i.created_at < h.hour + interval '1 hour'
+		WHERE i.action = 'VIEW'`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt, arg); err != nil {
+			return fmt.Errorf("failed to rebuild rollup hours: %w", err)
+		}
+	}
+	return nil
+}
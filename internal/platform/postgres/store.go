@@ -3,11 +3,81 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"campaign-management/internal/campaign"
+
+	"github.com/lib/pq"
 )
 
+// campaignColumns must stay in sync with scanCampaign.
+const campaignColumns = `id, title, COALESCE(image_url, ''), COALESCE(action_url, ''), priority, COALESCE(weight, 0), start_time, end_time, max_frequency, COALESCE(cap_per_day, 0), COALESCE(cap_per_week, 0), COALESCE(click_cap_per_day, 0), COALESCE(click_cap_per_week, 0), COALESCE(click_cap_lifetime, 0), COALESCE(dismiss_hides, false), COALESCE(budget, 0), COALESCE(pacing, ''), target_type, COALESCE(target_segment, ''), target_rules, variants, schedule, placement, is_active`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCampaign(row scanner, extra ...any) (*campaign.Campaign, error) {
+	c := &campaign.Campaign{}
+	var rules, variants, schedule []byte
+	var start, end sql.NullTime // NULL in rows written outside the API
+	dest := append([]any{
+		&c.ID, &c.Title, &c.ImageURL, &c.ActionURL, &c.Priority, &c.Weight, &start, &end, &c.MaxFrequency, &c.FrequencyCap.PerDay, &c.FrequencyCap.PerWeek,
+		&c.ClickCap.PerDay, &c.ClickCap.PerWeek, &c.ClickCap.Lifetime, &c.DismissHides, &c.Budget, &c.Pacing, &c.TargetType, &c.TargetSegment, &rules, &variants, &schedule, &c.Placement, &c.IsActive,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	c.FrequencyCap.Lifetime = c.MaxFrequency // max_frequency holds the lifetime cap
+
+	// NULL times read as zero: a zero end_time never serves, see ListActive
+	c.StartTime, c.EndTime = start.Time, end.Time
+	if len(rules) > 0 {
+		if err := json.Unmarshal(rules, &c.Rules); err != nil {
+			return nil, fmt.Errorf("invalid target_rules of campaign %d: %w", c.ID, err)
+		}
+	}
+	if len(variants) > 0 {
+		if err := json.Unmarshal(variants, &c.Variants); err != nil {
+			return nil, fmt.Errorf("invalid variants of campaign %d: %w", c.ID, err)
+		}
+	}
+	if len(schedule) > 0 {
+		if err := json.Unmarshal(schedule, &c.Schedule); err != nil {
+			return nil, fmt.Errorf("invalid schedule of campaign %d: %w", c.ID, err)
+		}
+	}
+	return c, nil
+}
+
+// jsonValue encodes a list for a JSONB column such as target_rules or variants (NULL when empty).
+func jsonValue[S ~[]E, E any](list S) (any, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// jsonObject encodes an optional object for a JSONB column such as schedule (NULL when nil).
+func jsonObject[T any](v *T) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
 type Store struct {
 	db *sql.DB
 }
@@ -18,15 +88,33 @@ func NewStore(db *sql.DB) *Store {
 
 func (s *Store) Create(ctx context.Context, c *campaign.Campaign) error {
 	query := `
-		INSERT INTO campaigns (title, image_url, action_url, priority, start_time, end_time, max_frequency, target_type, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO campaigns (title, image_url, action_url, priority, start_time, end_time, max_frequency, cap_per_day, cap_per_week,
+			click_cap_per_day, click_cap_per_week, click_cap_lifetime, dismiss_hides, target_type, target_segment, target_rules, placement, is_active, weight, variants, budget, pacing, schedule)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17, $18, $19, $20, $21, NULLIF($22, ''), $23)
 		RETURNING id
 	`
-	err := s.db.QueryRowContext(ctx, query,
-		c.Title, c.ImageURL, c.ActionURL, c.Priority, c.StartTime, c.EndTime, c.MaxFrequency, c.TargetType, c.IsActive,
+	fc := c.Cap()
+	rules, err := jsonValue(c.Rules)
+	if err != nil {
+		return err
+	}
+	variants, err := jsonValue(c.Variants)
+	if err != nil {
+		return err
+	}
+	schedule, err := jsonObject(c.Schedule)
+	if err != nil {
+		return err
+	}
+	err = s.db.QueryRowContext(ctx, query,
+		c.Title, c.ImageURL, c.ActionURL, c.Priority, c.StartTime, c.EndTime, fc.Lifetime, fc.PerDay, fc.PerWeek,
+		c.ClickCap.PerDay, c.ClickCap.PerWeek, c.ClickCap.Lifetime, c.DismissHides, c.TargetType, c.TargetSegment, rules, c.Placement, c.IsActive, c.Weight, variants, c.Budget, c.Pacing, schedule,
 	).Scan(&c.ID)
-	
+
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return campaign.ErrSegmentNotFound // Deleted since checkTargeting
+		}
 		return fmt.Errorf("failed to create campaign: %w", err)
 	}
 	return nil
@@ -35,13 +123,32 @@ func (s *Store) Create(ctx context.Context, c *campaign.Campaign) error {
 func (s *Store) Update(ctx context.Context, c *campaign.Campaign) error {
 	query := `
 		UPDATE campaigns 
-		SET title=$1, image_url=$2, action_url=$3, priority=$4, start_time=$5, end_time=$6, max_frequency=$7, target_type=$8, is_active=$9
-		WHERE id=$10
+		SET title=$1, image_url=$2, action_url=$3, priority=$4, start_time=$5, end_time=$6, max_frequency=$7, cap_per_day=$8, cap_per_week=$9,
+			click_cap_per_day=$10, click_cap_per_week=$11, click_cap_lifetime=$12, dismiss_hides=$13,
+			target_type=$14, target_segment=NULLIF($15, ''), target_rules=$16, placement=$17, is_active=$18, weight=$19, variants=$20, budget=$21, pacing=NULLIF($22, ''), schedule=$23
+		WHERE id=$24
 	`
+	fc := c.Cap()
+	rules, err := jsonValue(c.Rules)
+	if err != nil {
+		return err
+	}
+	variants, err := jsonValue(c.Variants)
+	if err != nil {
+		return err
+	}
+	schedule, err := jsonObject(c.Schedule)
+	if err != nil {
+		return err
+	}
 	res, err := s.db.ExecContext(ctx, query,
-		c.Title, c.ImageURL, c.ActionURL, c.Priority, c.StartTime, c.EndTime, c.MaxFrequency, c.TargetType, c.IsActive, c.ID,
+		c.Title, c.ImageURL, c.ActionURL, c.Priority, c.StartTime, c.EndTime, fc.Lifetime, fc.PerDay, fc.PerWeek,
+		c.ClickCap.PerDay, c.ClickCap.PerWeek, c.ClickCap.Lifetime, c.DismissHides, c.TargetType, c.TargetSegment, rules, c.Placement, c.IsActive, c.Weight, variants, c.Budget, c.Pacing, schedule, c.ID,
 	)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return campaign.ErrSegmentNotFound // Deleted since checkTargeting
+		}
 		return fmt.Errorf("failed to update campaign: %w", err)
 	}
 	rows, _ := res.RowsAffected()
@@ -52,21 +159,36 @@ func (s *Store) Update(ctx context.Context, c *campaign.Campaign) error {
 }
 
 func (s *Store) Delete(ctx context.Context, id int64) error {
-	// Hard delete for simplicity
-	query := `DELETE FROM campaigns WHERE id = $1`
-	_, err := s.db.ExecContext(ctx, query, id)
-	return err
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Targets reference the campaign, drop them first
+	if err := clearTargets(ctx, tx, campaign.CampaignAudience(id)); err != nil {
+		return err
+	}
+	// Hard delete for simplicity: the events go with it (ON DELETE CASCADE) and so do the
+	// rollups, which have no foreign key
+	for _, query := range []string{
+		`DELETE FROM campaign_metrics_hourly WHERE campaign_id = $1`,
+		`DELETE FROM campaign_viewers_hourly WHERE campaign_id = $1`,
+		`DELETE FROM campaigns WHERE id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to delete campaign: %w", err)
+		}
+	}
+	return tx.Commit()
 }
 
 func (s *Store) GetByID(ctx context.Context, id int64) (*campaign.Campaign, error) {
 	query := `
-		SELECT id, title, COALESCE(image_url, ''), COALESCE(action_url, ''), priority, start_time, end_time, max_frequency, target_type, is_active
+		SELECT ` + campaignColumns + `
 		FROM campaigns WHERE id = $1
 	`
-	c := &campaign.Campaign{}
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&c.ID, &c.Title, &c.ImageURL, &c.ActionURL, &c.Priority, &c.StartTime, &c.EndTime, &c.MaxFrequency, &c.TargetType, &c.IsActive,
-	)
+	c, err := scanCampaign(s.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil // Return nil if not found
 	}
@@ -78,7 +200,7 @@ func (s *Store) GetByID(ctx context.Context, id int64) (*campaign.Campaign, erro
 
 func (s *Store) List(ctx context.Context) ([]*campaign.Campaign, error) {
 	query := `
-		SELECT id, title, COALESCE(image_url, ''), COALESCE(action_url, ''), priority, start_time, end_time, max_frequency, target_type, is_active
+		SELECT ` + campaignColumns + `
 		FROM campaigns ORDER BY id DESC LIMIT 100
 	`
 	rows, err := s.db.QueryContext(ctx, query)
@@ -89,13 +211,311 @@ func (s *Store) List(ctx context.Context) ([]*campaign.Campaign, error) {
 
 	var result []*campaign.Campaign
 	for rows.Next() {
-		c := &campaign.Campaign{}
-		if err := rows.Scan(
-			&c.ID, &c.Title, &c.ImageURL, &c.ActionURL, &c.Priority, &c.StartTime, &c.EndTime, &c.MaxFrequency, &c.TargetType, &c.IsActive,
-		); err != nil {
+		c, err := scanCampaign(rows)
+		if err != nil {
 			return nil, err
 		}
 		result = append(result, c)
 	}
 	return result, nil
 }
+
+// ListActive returns every campaign that should be live in Redis (active and not ended).
+// A NULL end_time is invalid rather than open-ended: counter TTLs and pacing need an end.
+func (s *Store) ListActive(ctx context.Context) ([]*campaign.Campaign, error) {
+	query := `
+		SELECT ` + campaignColumns + `
+		FROM campaigns
+		WHERE is_active AND end_time > NOW()
+		ORDER BY id DESC
+	`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*campaign.Campaign
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// RecordEvents appends events to campaign_impressions in a single INSERT.
+// Rows whose (user_id, event_id) is already logged are skipped, so client retries stay out of analytics.
+func (s *Store) RecordEvents(ctx context.Context, events []campaign.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	eventIDs := make([]string, len(events))
+	campaignIDs := make([]int64, len(events))
+	variantIDs := make([]string, len(events))
+	userIDs := make([]int64, len(events))
+	actions := make([]string, len(events))
+	ats := make([]string, len(events)) // pq.Array has no timestamp encoding, send RFC 3339 text
+	for i, e := range events {
+		eventIDs[i], campaignIDs[i], userIDs[i], actions[i], ats[i] = e.EventID, e.CampaignID, e.UserID, string(e.Action), e.At.Format(time.RFC3339Nano)
+		variantIDs[i] = e.VariantID
+	}
+
+	query := `
+		INSERT INTO campaign_impressions (event_id, campaign_id, variant_id, user_id, action, created_at)
+		SELECT NULLIF(e.event_id, ''), e.campaign_id, e.variant_id, e.user_id, e.action, e.created_at
+		FROM unnest($1::varchar[], $2::bigint[], $3::varchar[], $4::bigint[], $5::varchar[], $6::timestamptz[])
+			AS e(event_id, campaign_id, variant_id, user_id, action, created_at)
+		ON CONFLICT (user_id, event_id) WHERE event_id IS NOT NULL DO NOTHING
+	`
+	_, err := s.db.ExecContext(ctx, query, pq.Array(eventIDs), pq.Array(campaignIDs), pq.Array(variantIDs), pq.Array(userIDs), pq.Array(actions), pq.Array(ats))
+	if err != nil {
+		if isInvalidData(err) {
+			return fmt.Errorf("%w: %v", campaign.ErrEventRejected, err) // Retrying the batch would fail again
+		}
+		return fmt.Errorf("failed to record events: %w", err)
+	}
+	return nil
+}
+
+// --- Audience Membership ---
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// audienceTable maps an audience to its membership table, owner column and owner value.
+func audienceTable(a campaign.Audience) (table, column string, owner any) {
+	if a.IsSegment() {
+		return "segment_members", "segment_name", a.Segment
+	}
+	return "campaign_targets", "campaign_id", a.CampaignID
+}
+
+func insertTargets(ctx context.Context, db execer, a campaign.Audience, userIDs []int64) error {
+	table, column, owner := audienceTable(a)
+	query := fmt.Sprintf(`
+		INSERT INTO %s (%s, user_id)
+		SELECT $1, unnest($2::bigint[])
+		ON CONFLICT DO NOTHING
+	`, table, column)
+	if _, err := db.ExecContext(ctx, query, owner, pq.Array(userIDs)); err != nil {
+		return fmt.Errorf("failed to add targets: %w", err)
+	}
+	return nil
+}
+
+func clearTargets(ctx context.Context, db execer, a campaign.Audience) error {
+	table, column, owner := audienceTable(a)
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1`, table, column)
+	if _, err := db.ExecContext(ctx, query, owner); err != nil {
+		return fmt.Errorf("failed to clear targets: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) AddTargets(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	return insertTargets(ctx, s.db, a, userIDs)
+}
+
+func (s *Store) RemoveTargets(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	table, column, owner := audienceTable(a)
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1 AND user_id = ANY($2)`, table, column)
+	if _, err := s.db.ExecContext(ctx, query, owner, pq.Array(userIDs)); err != nil {
+		return fmt.Errorf("failed to remove targets: %w", err)
+	}
+	return nil
+}
+
+// ReplaceTargets swaps the whole target list in one transaction.
+func (s *Store) ReplaceTargets(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := clearTargets(ctx, tx, a); err != nil {
+		return err
+	}
+	if err := insertTargets(ctx, tx, a, userIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) ListTargets(ctx context.Context, a campaign.Audience, afterUserID int64, limit int) ([]int64, error) {
+	table, column, owner := audienceTable(a)
+	query := fmt.Sprintf(`
+		SELECT user_id FROM %s
+		WHERE %s = $1 AND user_id > $2
+		ORDER BY user_id
+		LIMIT $3
+	`, table, column)
+	rows, err := s.db.QueryContext(ctx, query, owner, afterUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// targetImport writes every batch inside one transaction, so a failed upload leaves no trace.
+type targetImport struct {
+	tx       *sql.Tx
+	audience campaign.Audience
+}
+
+func (s *Store) BeginTargetImport(ctx context.Context, a campaign.Audience, replace bool) (campaign.TargetImport, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if replace {
+		if err := clearTargets(ctx, tx, a); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return &targetImport{tx: tx, audience: a}, nil
+}
+
+func (t *targetImport) Add(ctx context.Context, userIDs []int64) error {
+	return insertTargets(ctx, t.tx, t.audience, userIDs)
+}
+
+func (t *targetImport) Commit(ctx context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *targetImport) Abort(ctx context.Context) error {
+	return t.tx.Rollback()
+}
+
+// --- User Policy ---
+
+func (s *Store) GetUserPolicy(ctx context.Context) (*campaign.UserPolicy, error) {
+	query := `SELECT cooldown_seconds, per_day, per_week, updated_at FROM user_policy WHERE id`
+	p := &campaign.UserPolicy{}
+	err := s.db.QueryRowContext(ctx, query).Scan(&p.CooldownSeconds, &p.PerDay, &p.PerWeek, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil // Never set
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) SaveUserPolicy(ctx context.Context, p *campaign.UserPolicy) error {
+	query := `
+		INSERT INTO user_policy (id, cooldown_seconds, per_day, per_week, updated_at) VALUES (true, $1, $2, $3, NOW())
+		ON CONFLICT (id) DO UPDATE SET cooldown_seconds = $1, per_day = $2, per_week = $3, updated_at = NOW()
+		RETURNING updated_at
+	`
+	if err := s.db.QueryRowContext(ctx, query, p.CooldownSeconds, p.PerDay, p.PerWeek).Scan(&p.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save user policy: %w", err)
+	}
+	return nil
+}
+
+// --- Named Segments ---
+
+func (s *Store) CreateSegment(ctx context.Context, seg *campaign.Segment) error {
+	query := `
+		INSERT INTO segments (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING created_at
+	`
+	err := s.db.QueryRowContext(ctx, query, seg.Name, seg.Description).Scan(&seg.CreatedAt)
+	if err == sql.ErrNoRows {
+		return campaign.ErrSegmentExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetSegment(ctx context.Context, name string) (*campaign.Segment, error) {
+	query := `SELECT name, COALESCE(description, ''), created_at FROM segments WHERE name = $1`
+	seg := &campaign.Segment{}
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&seg.Name, &seg.Description, &seg.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil // Return nil if not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return seg, nil
+}
+
+func (s *Store) ListSegments(ctx context.Context) ([]*campaign.Segment, error) {
+	query := `SELECT name, COALESCE(description, ''), created_at FROM segments ORDER BY name`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*campaign.Segment{}
+	for rows.Next() {
+		seg := &campaign.Segment{}
+		if err := rows.Scan(&seg.Name, &seg.Description, &seg.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, seg)
+	}
+	return result, rows.Err()
+}
+
+// DeleteSegment removes the segment; segment_members rows go with it (ON DELETE CASCADE).
+func (s *Store) DeleteSegment(ctx context.Context, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM segments WHERE name = $1`, name)
+	if isForeignKeyViolation(err) {
+		return campaign.ErrSegmentInUse // Referenced since the usage check
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete segment: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return campaign.ErrSegmentNotFound
+	}
+	return nil
+}
+
+// isForeignKeyViolation reports a write rejected by a REFERENCES constraint, such as
+// campaigns.target_segment.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// isInvalidData reports errors caused by the rows themselves (data exception or integrity
+// constraint violation), as opposed to the connection or the server.
+func isInvalidData(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	class := pqErr.Code.Class()
+	return class == "22" || class == "23"
+}
+
+func (s *Store) CountSegmentUsage(ctx context.Context, name string) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM campaigns WHERE target_segment = $1`, name).Scan(&n)
+	return n, err
+}
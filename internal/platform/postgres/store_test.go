@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"campaign-management/internal/campaign"
+)
+
+// testDB connects to $TEST_DATABASE_URL and applies db/schema.sql, or skips the test when it is unset.
+// The database should be a scratch one: tests leave their rows behind.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	schema, err := os.ReadFile("../../../db/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("apply schema: %v", err)
+	}
+	return db
+}
+
+func TestStoreDeleteWithEvents(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	store := NewStore(db)
+
+	now := time.Now().UTC()
+	c := &campaign.Campaign{
+		Title: "delete me", Priority: 1, StartTime: now.Add(-48 * time.Hour), EndTime: now.Add(time.Hour),
+		TargetType: campaign.TargetTypeAll, Placement: campaign.DefaultPlacement, IsActive: true,
+	}
+	if err := store.Create(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	events := []campaign.Event{
+		{CampaignID: c.ID, UserID: 1, Action: campaign.ActionView, At: now.Add(-25 * time.Hour)},
+		{CampaignID: c.ID, UserID: 1, Action: campaign.ActionClick, At: now.Add(-25 * time.Hour)},
+	}
+	if err := store.RecordEvents(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RollupEvents(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete(ctx, c.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, err := store.GetByID(ctx, c.ID); err != nil || got != nil {
+		t.Fatalf("GetByID after Delete = %v, %v, want nil", got, err)
+	}
+	for _, table := range []string{"campaign_impressions", "campaign_metrics_hourly", "campaign_viewers_hourly"} {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE campaign_id = $1`, c.ID).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%s keeps %d rows of the deleted campaign", table, n)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"campaign-management/internal/campaign"
@@ -24,7 +26,7 @@ func NewRepository(rdb *redis.Client) *Repository {
 func (r *Repository) GetActiveCampaignIDs(ctx context.Context) ([]int64, error) {
 	// Key: campaigns:active (ZSET score=priority, member=id)
 	// ZREVRANGE 0 -1 to get all, highest priority first
-	idsStr, err := r.rdb.ZRevRange(ctx, "campaigns:active", 0, -1).Result()
+	idsStr, err := r.rdb.ZRevRange(ctx, activeKey, 0, -1).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active campaigns: %w", err)
 	}
@@ -74,66 +76,371 @@ func (r *Repository) GetCampaignsMetadata(ctx context.Context, ids []int64) (map
 	return result, nil
 }
 
-// IsUserTargeted checks if a user is in the target list (BITMAP).
+// audienceKey is the bitmap holding an audience's members.
+func audienceKey(a campaign.Audience) string {
+	if a.IsSegment() {
+		return fmt.Sprintf("segment:%s:users", a.Segment)
+	}
+	return fmt.Sprintf("campaign:%d:users", a.CampaignID)
+}
+
+// audienceKeyLua mirrors Campaign.Audience inside scripts: the named segment's bitmap when the
+// metadata sets target_segment, the campaign's own bitmap otherwise. decode_meta returns nil
+// for missing or malformed metadata.
+const audienceKeyLua = `
+local function decode_meta(meta)
+	if not meta then
+		return nil
+	end
+	local ok, c = pcall(cjson.decode, meta)
+	if ok and type(c) == 'table' then
+		return c
+	end
+	return nil
+end
+
+local function audience_key(id, c)
+	if c and type(c.target_segment) == 'string' and c.target_segment ~= '' then
+		return 'segment:' .. c.target_segment .. ':users'
+	end
+	return 'campaign:' .. id .. ':users'
+end
+`
+
+var targetedScript = redis.NewScript(audienceKeyLua + `
+local c = decode_meta(redis.call('GET', 'campaign:' .. ARGV[1] .. ':meta'))
+return redis.call('GETBIT', audience_key(ARGV[1], c), ARGV[2])
+`)
+
+// bitmapUser reports whether userID fits a bitmap offset; GETBIT fails outside that range.
+func bitmapUser(userID int64) bool {
+	return userID >= 0 && userID <= campaign.MaxUserID
+}
+
+// IsUserTargeted checks if a user is in the campaign's audience (BITMAP), resolving named segments.
 func (r *Repository) IsUserTargeted(ctx context.Context, campaignID int64, userID int64) (bool, error) {
-	key := fmt.Sprintf("campaign:%d:users", campaignID)
+	if !bitmapUser(userID) {
+		return false, nil // Not addressable by any audience
+	}
 	// GETBIT returns 0 or 1
-	bit, err := r.rdb.GetBit(ctx, key, int64(userID)).Result()
+	bit, err := targetedScript.Run(ctx, r.rdb, nil, campaignID, userID).Int64()
 	if err != nil {
 		return false, err
 	}
 	return bit == 1, nil
 }
 
-// GetUserImpressions fetches how many times a user has seen specific campaigns.
-func (r *Repository) GetUserImpressions(ctx context.Context, userID int64, campaignIDs []int64) (map[int64]int, error) {
-	key := fmt.Sprintf("user:%d:impressions", userID)
-	
-	// HMGET
+// counterKeys returns the lifetime, daily and weekly hashes of one counter ("impressions", "clicks") of a user.
+// Daily/weekly hashes are keyed by window so they reset naturally instead of via a sliding TTL.
+func counterKeys(counter string, userID int64, w campaign.CapWindows) (lifetime, day, week string) {
+	lifetime = fmt.Sprintf("user:%d:%s", userID, counter)
+	return lifetime, lifetime + ":d:" + w.Day, lifetime + ":w:" + w.Week
+}
+
+func impressionKeys(userID int64, w campaign.CapWindows) (lifetime, day, week string) {
+	return counterKeys("impressions", userID, w)
+}
+
+func clickKeys(userID int64, w campaign.CapWindows) (lifetime, day, week string) {
+	return counterKeys("clicks", userID, w)
+}
+
+const (
+	activeKey     = "campaigns:active"     // Every active campaign, across placements
+	placementsKey = "campaigns:placements" // SET of placements that have an active ZSET
+	policyKey     = "settings:user_policy" // UserPolicy JSON
+)
+
+// placementActiveKey is the ZSET of active campaigns on one placement (score=priority, member=id).
+func placementActiveKey(placement string) string {
+	return activeKey + ":" + placement
+}
+
+// deliveredKey counts a campaign's impressions across all users, for its budget.
+func deliveredKey(campaignID int64) string {
+	return fmt.Sprintf("campaign:%d:delivered", campaignID)
+}
+
+// activityKey is the hash of a user's popups across all campaigns:
+// last (unix seconds), day + day_n, week + week_n (window ID and count).
+func activityKey(userID int64) string {
+	return fmt.Sprintf("user:%d:popups", userID)
+}
+
+// dismissedKey is the hash of campaigns a user has dismissed (field = campaign ID).
+func dismissedKey(userID int64) string {
+	return fmt.Sprintf("user:%d:dismissed", userID)
+}
+
+// GetUserImpressions fetches how many times a user has seen specific campaigns, per cap window.
+func (r *Repository) GetUserImpressions(ctx context.Context, userID int64, campaignIDs []int64, at time.Time) (map[int64]campaign.ImpressionCounts, error) {
+	lifetimeKey, dayKey, weekKey := impressionKeys(userID, campaign.WindowsAt(at))
+
+	// HMGET x3 (Pipeline)
 	fields := make([]string, len(campaignIDs))
 	for i, id := range campaignIDs {
 		fields[i] = strconv.FormatInt(id, 10)
 	}
 
-	vals, err := r.rdb.HMGet(ctx, key, fields...).Result()
-	if err != nil {
+	pipe := r.rdb.Pipeline()
+	lifetimeCmd := pipe.HMGet(ctx, lifetimeKey, fields...)
+	dayCmd := pipe.HMGet(ctx, dayKey, fields...)
+	weekCmd := pipe.HMGet(ctx, weekKey, fields...)
+	if _, err := pipe.Exec(ctx); err != nil {
 		return nil, err
 	}
 
-	result := make(map[int64]int)
-	for i, val := range vals {
-		if val == nil {
-			result[campaignIDs[i]] = 0
+	lifetime, day, week := lifetimeCmd.Val(), dayCmd.Val(), weekCmd.Val()
+	result := make(map[int64]campaign.ImpressionCounts, len(campaignIDs))
+	for i, id := range campaignIDs {
+		result[id] = campaign.ImpressionCounts{
+			Day:      toCount(day[i]),
+			Week:     toCount(week[i]),
+			Lifetime: toCount(lifetime[i]),
+		}
+	}
+	return result, nil
+}
+
+// toCount converts a hash field reply (nil when missing) to an int.
+func toCount(val interface{}) int {
+	// Redis returns string or int depending on client version, handle safe conversion
+	switch v := val.(type) {
+	case string:
+		count, _ := strconv.Atoi(v)
+		return count
+	case int64: // if redis client auto-parses
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// snapshotScript reads a placement's active ZSET and, for every member, its metadata, the user's
+// targeting bit (campaign or segment bitmap, SEGMENT campaigns only and only when ARGV[2] is 1),
+// the user's lifetime/day/week impression and click counts, whether they dismissed it and the
+// campaign's delivered impressions. It returns {rows, user policy, user activity}. Keys are built
+// inside the script, so it assumes a single Redis node (no Cluster hash-slot routing).
+var snapshotScript = redis.NewScript(audienceKeyLua + `
+local ids = redis.call('ZREVRANGE', KEYS[1], 0, -1)
+local res = {}
+for i, id in ipairs(ids) do
+	local meta = redis.call('GET', 'campaign:' .. id .. ':meta')
+	local bit = 0
+	local c = decode_meta(meta)
+	if ARGV[2] == '1' and c and c.target_type == 'SEGMENT' then
+		bit = redis.call('GETBIT', audience_key(id, c), ARGV[1])
+	end
+	local row = {id, meta, bit}
+	for k = 2, 8 do
+		row[k + 2] = redis.call('HGET', KEYS[k], id)
+	end
+	row[11] = redis.call('GET', 'campaign:' .. id .. ':delivered')
+	res[i] = row
+end
+return {res, redis.call('GET', KEYS[9]), redis.call('HMGET', KEYS[10], 'last', 'day', 'day_n', 'week', 'week_n')}
+`)
+
+// activityScript counts a popup in the user's activity hash, resetting a window's count when
+// the window changed. Only the current day and week are kept, so the hash never grows.
+var activityScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'day') ~= ARGV[2] then
+	redis.call('HSET', KEYS[1], 'day', ARGV[2], 'day_n', 0)
+end
+if redis.call('HGET', KEYS[1], 'week') ~= ARGV[3] then
+	redis.call('HSET', KEYS[1], 'week', ARGV[3], 'week_n', 0)
+end
+redis.call('HINCRBY', KEYS[1], 'day_n', 1)
+redis.call('HINCRBY', KEYS[1], 'week_n', 1)
+if tonumber(redis.call('HGET', KEYS[1], 'last') or '0') < tonumber(ARGV[1]) then
+	redis.call('HSET', KEYS[1], 'last', ARGV[1])
+end
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+// GetEvaluationSnapshot fetches active IDs, metadata, targeting bits, impressions, clicks, dismissals,
+// budget delivery and the user policy with the user's activity in one round-trip (Lua).
+func (r *Repository) GetEvaluationSnapshot(ctx context.Context, userID int64, placement string, at time.Time) (*campaign.EvaluationSnapshot, error) {
+	w := campaign.WindowsAt(at)
+	lifetimeKey, dayKey, weekKey := impressionKeys(userID, w)
+	clickLifetimeKey, clickDayKey, clickWeekKey := clickKeys(userID, w)
+	keys := []string{placementActiveKey(placement), lifetimeKey, dayKey, weekKey, clickLifetimeKey, clickDayKey, clickWeekKey, dismissedKey(userID),
+		policyKey, activityKey(userID)}
+	// A user ID outside the bitmap range is in no audience, but may still see ALL and RULES campaigns
+	checkBits := 0
+	if bitmapUser(userID) {
+		checkBits = 1
+	}
+	reply, err := snapshotScript.Run(ctx, r.rdb, keys, userID, checkBits).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run snapshot script: %w", err)
+	}
+	if len(reply) != 3 {
+		return nil, fmt.Errorf("unexpected snapshot reply of %d elements", len(reply))
+	}
+	raw, _ := reply[0].([]interface{})
+
+	snap := &campaign.EvaluationSnapshot{
+		ActiveIDs:   make([]int64, 0, len(raw)),
+		Campaigns:   make(map[int64]*campaign.Campaign, len(raw)),
+		Targeted:    make(map[int64]bool, len(raw)),
+		Impressions: make(map[int64]campaign.ImpressionCounts, len(raw)),
+		Clicks:      make(map[int64]campaign.ImpressionCounts, len(raw)),
+		Dismissed:   make(map[int64]bool, len(raw)),
+		Delivered:   make(map[int64]int64, len(raw)),
+	}
+	for _, row := range raw {
+		// Missing keys come back as Lua false, i.e. nil in the reply
+		fields, ok := row.([]interface{})
+		if !ok || len(fields) < 11 {
 			continue
 		}
-		
-		// Redis returns string or int depending on client version, handle safe conversion
-		switch v := val.(type) {
-		case string:
-			count, _ := strconv.Atoi(v)
-			result[campaignIDs[i]] = count
-		case int64: // if redis client auto-parses
-			result[campaignIDs[i]] = int(v)
-		default:
-			result[campaignIDs[i]] = 0
+		idStr, _ := fields[0].(string)
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			continue // Skip invalid IDs
+		}
+		snap.ActiveIDs = append(snap.ActiveIDs, id)
+
+		if meta, ok := fields[1].(string); ok {
+			var c campaign.Campaign
+			if err := json.Unmarshal([]byte(meta), &c); err == nil {
+				c.ID = id
+				snap.Campaigns[id] = &c
+			}
+		}
+		if bit, ok := fields[2].(int64); ok {
+			snap.Targeted[id] = bit == 1
 		}
+		snap.Impressions[id] = campaign.ImpressionCounts{
+			Lifetime: toCount(fields[3]),
+			Day:      toCount(fields[4]),
+			Week:     toCount(fields[5]),
+		}
+		snap.Clicks[id] = campaign.ImpressionCounts{
+			Lifetime: toCount(fields[6]),
+			Day:      toCount(fields[7]),
+			Week:     toCount(fields[8]),
+		}
+		snap.Dismissed[id] = toCount(fields[9]) > 0
+		snap.Delivered[id] = int64(toCount(fields[10]))
 	}
-	return result, nil
+
+	if policy, ok := reply[1].(string); ok {
+		var p campaign.UserPolicy
+		if err := json.Unmarshal([]byte(policy), &p); err != nil {
+			return nil, fmt.Errorf("failed to decode user policy: %w", err)
+		}
+		snap.Policy = &p
+	}
+	if activity, ok := reply[2].([]interface{}); ok && len(activity) == 5 {
+		if last := toCount(activity[0]); last > 0 {
+			snap.Activity.LastSeen = time.Unix(int64(last), 0)
+		}
+		if day, _ := activity[1].(string); day == w.Day {
+			snap.Activity.Day = toCount(activity[2])
+		}
+		if week, _ := activity[3].(string); week == w.Week {
+			snap.Activity.Week = toCount(activity[4])
+		}
+	}
+	return snap, nil
 }
 
-func (r *Repository) IncrementImpression(ctx context.Context, userID int64, campaignID int64) error {
-	key := fmt.Sprintf("user:%d:impressions", userID)
-	// Increment by 1
-	if err := r.rdb.HIncrBy(ctx, key, strconv.FormatInt(campaignID, 10), 1).Err(); err != nil {
-		return err
+// IncrementImpression bumps the lifetime, daily and weekly impression counters, the
+// campaign's delivered count and the user's activity atomically (MULTI).
+func (r *Repository) IncrementImpression(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	w := campaign.WindowsAt(at)
+	lifetimeKey, dayKey, weekKey := impressionKeys(userID, w)
+
+	pipe := r.rdb.TxPipeline()
+	queueCounters(ctx, pipe, c, at, w, lifetimeKey, dayKey, weekKey)
+	// Counted with or without a budget, so one added mid-flight starts from the real delivery
+	delivered, ttl := deliveredKey(c.ID), lifetimeTTL(c, at)
+	pipe.Incr(ctx, delivered)
+	pipe.ExpireNX(ctx, delivered, ttl)
+	pipe.ExpireGT(ctx, delivered, ttl)
+	// EVAL sends the script itself: EVALSHA's fallback cannot work inside MULTI
+	activityScript.Eval(ctx, pipe, []string{activityKey(userID)}, at.Unix(), w.Day, w.Week, int(campaign.UserActivityTTL.Seconds()))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IncrementClick bumps the lifetime, daily and weekly click counters atomically (MULTI).
+func (r *Repository) IncrementClick(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	w := campaign.WindowsAt(at)
+	lifetimeKey, dayKey, weekKey := clickKeys(userID, w)
+
+	pipe := r.rdb.TxPipeline()
+	queueCounters(ctx, pipe, c, at, w, lifetimeKey, dayKey, weekKey)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// MarkDismissed flags the campaign in the user's dismissed hash, kept as long as the lifetime counters.
+func (r *Repository) MarkDismissed(ctx context.Context, userID int64, c *campaign.Campaign, at time.Time) error {
+	key := dismissedKey(userID)
+	ttl := lifetimeTTL(c, at)
+
+	pipe := r.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, key, strconv.FormatInt(c.ID, 10), 1)
+	pipe.ExpireNX(ctx, key, ttl)
+	pipe.ExpireGT(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ClaimEvent records a client event ID with SET NX; the key expires after the dedupe window.
+func (r *Repository) ClaimEvent(ctx context.Context, userID int64, eventID string) (bool, error) {
+	return r.rdb.SetNX(ctx, eventKey(userID, eventID), 1, campaign.EventDedupeWindow).Result()
+}
+
+func (r *Repository) ReleaseEvent(ctx context.Context, userID int64, eventID string) error {
+	return r.rdb.Del(ctx, eventKey(userID, eventID)).Err()
+}
+
+// eventKey marks a client event ID as recorded for EventDedupeWindow.
+func eventKey(userID int64, eventID string) string {
+	return fmt.Sprintf("user:%d:event:%s", userID, eventID)
+}
+
+// queueCounters bumps one campaign in a set of lifetime/day/week hashes.
+// Window hashes expire at the end of their window; the lifetime hash lives until the
+// latest campaign end seen for this user, plus a day of grace.
+func queueCounters(ctx context.Context, pipe redis.Pipeliner, c *campaign.Campaign, at time.Time, w campaign.CapWindows, lifetimeKey, dayKey, weekKey string) {
+	field := strconv.FormatInt(c.ID, 10)
+	ttl := lifetimeTTL(c, at)
+
+	pipe.HIncrBy(ctx, lifetimeKey, field, 1)
+	pipe.HIncrBy(ctx, dayKey, field, 1)
+	pipe.HIncrBy(ctx, weekKey, field, 1)
+	pipe.ExpireAt(ctx, dayKey, w.DayEnd)
+	pipe.ExpireAt(ctx, weekKey, w.WeekEnd)
+	// NX sets the first TTL, GT only ever extends it (GT alone ignores keys without TTL)
+	pipe.ExpireNX(ctx, lifetimeKey, ttl)
+	pipe.ExpireGT(ctx, lifetimeKey, ttl)
+}
+
+// lifetimeTTL keeps per-user lifetime state until the campaign ends, plus a day of grace. at is
+// the service clock's now, like the windows, so a simulated instant gets matching TTLs.
+func lifetimeTTL(c *campaign.Campaign, at time.Time) time.Duration {
+	ttl := c.EndTime.Sub(at) + 24*time.Hour
+	if ttl < 24*time.Hour {
+		ttl = 24 * time.Hour
 	}
-	// Set TTL to 24h just in case, ensuring it doesn't grow forever if logic changes
-	r.rdb.Expire(ctx, key, 24*time.Hour)
-	return nil
+	return ttl
 }
 
-// SaveCampaign syncs metadata to Redis and updates "Active" list if needed.
+// SaveCampaign syncs metadata to Redis and updates the active ZSETs (global and the campaign's
+// placement) if needed. The campaign is removed from every other placement, in case it moved.
 func (r *Repository) SaveCampaign(ctx context.Context, c *campaign.Campaign) error {
+	placements, err := r.rdb.SMembers(ctx, placementsKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read placements: %w", err)
+	}
+	placement := c.PlacementName()
+
 	pipe := r.rdb.Pipeline()
 
 	// 1. Save Meta (Always)
@@ -141,23 +448,66 @@ func (r *Repository) SaveCampaign(ctx context.Context, c *campaign.Campaign) err
 	key := fmt.Sprintf("campaign:%d:meta", c.ID)
 	pipe.Set(ctx, key, bytes, 0) // No TTL for now, or match campaign end time
 
-	// 2. Manage Active List
-	activeKey := "campaigns:active"
+	// 2. Manage Active Lists
+	for _, p := range placements {
+		if p != placement {
+			pipe.ZRem(ctx, placementActiveKey(p), c.ID)
+		}
+	}
 	if c.IsActive {
 		// Add/Update score
-		pipe.ZAdd(ctx, activeKey, redis.Z{Score: float64(c.Priority), Member: c.ID})
+		z := redis.Z{Score: float64(c.Priority), Member: c.ID}
+		pipe.ZAdd(ctx, activeKey, z)
+		pipe.ZAdd(ctx, placementActiveKey(placement), z)
+		pipe.SAdd(ctx, placementsKey, placement)
 	} else {
 		// Remove if inactive
 		pipe.ZRem(ctx, activeKey, c.ID)
+		pipe.ZRem(ctx, placementActiveKey(placement), c.ID)
 	}
 
-	_, err := pipe.Exec(ctx)
+	_, err = pipe.Exec(ctx)
 	return err
 }
 
+// SaveUserPolicy mirrors the user policy for the snapshot script.
+func (r *Repository) SaveUserPolicy(ctx context.Context, p *campaign.UserPolicy) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, policyKey, b, 0).Err()
+}
+
+// restoreDeliveredScript sets the delivered counter to ARGV[1] if it is lower, keeping its TTL,
+// and extends the TTL to ARGV[2] seconds like IncrementImpression does.
+var restoreDeliveredScript = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[1]) or '0') < tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[1], ARGV[1], 'KEEPTTL')
+end
+redis.call('EXPIRE', KEYS[1], ARGV[2], 'NX')
+redis.call('EXPIRE', KEYS[1], ARGV[2], 'GT')
+return 1
+`)
+
+// RestoreDelivered raises campaign:{id}:delivered to the DB count. The DB lags behind while
+// events are buffered, so a higher counter is kept.
+func (r *Repository) RestoreDelivered(ctx context.Context, c *campaign.Campaign, count int64, at time.Time) error {
+	ttl := int(lifetimeTTL(c, at).Seconds())
+	return restoreDeliveredScript.Run(ctx, r.rdb, []string{deliveredKey(c.ID)}, count, ttl).Err()
+}
+
 func (r *Repository) RemoveCampaign(ctx context.Context, id int64) error {
+	placements, err := r.rdb.SMembers(ctx, placementsKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read placements: %w", err)
+	}
+
 	pipe := r.rdb.Pipeline()
-	pipe.ZRem(ctx, "campaigns:active", id)
+	pipe.ZRem(ctx, activeKey, id)
+	for _, p := range placements {
+		pipe.ZRem(ctx, placementActiveKey(p), id)
+	}
 	metaKey := fmt.Sprintf("campaign:%d:meta", id)
 	pipe.Del(ctx, metaKey)
 	// Set TTL to 24h just in case, ensuring it doesn't grow forever if logic changes
@@ -168,6 +518,195 @@ func (r *Repository) RemoveCampaign(ctx context.Context, id int64) error {
 	// For now, adding it to the pipeline for the metaKey.
 	pipe.Expire(ctx, metaKey, 24*time.Hour)
 	// Optional: Del other keys like targeting bit map if needed
-	_, err := pipe.Exec(ctx)
+	_, err = pipe.Exec(ctx)
 	return err
 }
+
+// AddTargetUsers sets the users' bits in the audience bitmap (Pipeline SETBIT).
+func (r *Repository) AddTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	return r.setTargetBits(ctx, audienceKey(a), userIDs, 1)
+}
+
+// RemoveTargetUsers clears the users' bits in the audience bitmap (Pipeline SETBIT).
+func (r *Repository) RemoveTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	return r.setTargetBits(ctx, audienceKey(a), userIDs, 0)
+}
+
+// ReplaceTargetUsers builds the new bitmap under a temporary key and RENAMEs it into place,
+// so readers never observe a half-written list.
+func (r *Repository) ReplaceTargetUsers(ctx context.Context, a campaign.Audience, userIDs []int64) error {
+	key := audienceKey(a)
+	if len(userIDs) == 0 {
+		return r.rdb.Del(ctx, key).Err()
+	}
+
+	tmpKey := fmt.Sprintf("%s:tmp:%d", key, time.Now().UnixNano())
+	if err := r.setTargetBits(ctx, tmpKey, userIDs, 1); err != nil {
+		r.rdb.Del(ctx, tmpKey)
+		return err
+	}
+	return r.rdb.Rename(ctx, tmpKey, key).Err()
+}
+
+func (r *Repository) setTargetBits(ctx context.Context, key string, userIDs []int64, value int) error {
+	pipe := r.rdb.Pipeline()
+	for _, uid := range userIDs {
+		pipe.SetBit(ctx, key, uid, value)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update target bitmap: %w", err)
+	}
+	return nil
+}
+
+// targetImport stages bits in a temporary key. Commit RENAMEs it over the live bitmap (replace)
+// or ORs it in (append), so readers only ever see the before or after state.
+type targetImport struct {
+	r       *Repository
+	key     string
+	tmpKey  string
+	replace bool
+	added   bool
+}
+
+func (r *Repository) BeginTargetImport(ctx context.Context, a campaign.Audience, replace bool) (campaign.TargetImport, error) {
+	key := audienceKey(a)
+	return &targetImport{
+		r:       r,
+		key:     key,
+		tmpKey:  fmt.Sprintf("%s:import:%d", key, time.Now().UnixNano()),
+		replace: replace,
+	}, nil
+}
+
+func (t *targetImport) Add(ctx context.Context, userIDs []int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if err := t.r.setTargetBits(ctx, t.tmpKey, userIDs, 1); err != nil {
+		return err
+	}
+	if !t.added {
+		// Safety net: a crashed import must not leave its staging key around forever
+		t.r.rdb.Expire(ctx, t.tmpKey, 24*time.Hour)
+		t.added = true
+	}
+	return nil
+}
+
+func (t *targetImport) Commit(ctx context.Context) error {
+	switch {
+	case !t.added && t.replace:
+		return t.r.rdb.Del(ctx, t.key).Err()
+	case !t.added:
+		return nil
+	case t.replace:
+		pipe := t.r.rdb.TxPipeline()
+		pipe.Rename(ctx, t.tmpKey, t.key)
+		pipe.Persist(ctx, t.key) // RENAME carries over the staging TTL
+		_, err := pipe.Exec(ctx)
+		return err
+	default:
+		pipe := t.r.rdb.TxPipeline()
+		pipe.BitOpOr(ctx, t.key, t.key, t.tmpKey)
+		pipe.Del(ctx, t.tmpKey)
+		_, err := pipe.Exec(ctx)
+		return err
+	}
+}
+
+func (t *targetImport) Abort(ctx context.Context) error {
+	return t.r.rdb.Del(ctx, t.tmpKey).Err()
+}
+
+// GetCacheState scans all campaign:*:meta keys and reads the active ZSETs (scores from the global one).
+func (r *Repository) GetCacheState(ctx context.Context) (*campaign.CacheState, error) {
+	state := &campaign.CacheState{
+		Metadata:   map[int64]*campaign.Campaign{},
+		Active:     map[int64]int{},
+		Placements: map[int64][]string{},
+	}
+
+	// 1. Active ZSET with scores
+	members, err := r.rdb.ZRangeWithScores(ctx, activeKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read active campaigns: %w", err)
+	}
+	for _, z := range members {
+		s, _ := z.Member.(string)
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			continue // Skip invalid IDs
+		}
+		state.Active[id] = int(z.Score)
+	}
+
+	// 2. Placement ZSETs
+	placements, err := r.rdb.SMembers(ctx, placementsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read placements: %w", err)
+	}
+	sort.Strings(placements)
+	for _, p := range placements {
+		members, err := r.rdb.ZRange(ctx, placementActiveKey(p), 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read active campaigns of placement %q: %w", p, err)
+		}
+		for _, s := range members {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				continue // Skip invalid IDs
+			}
+			state.Placements[id] = append(state.Placements[id], p)
+		}
+	}
+
+	// 3. Every metadata key, including ones no longer in the ZSET
+	var ids []int64
+	iter := r.rdb.Scan(ctx, 0, "campaign:*:meta", 500).Iterator()
+	for iter.Next(ctx) {
+		var id int64
+		if _, err := fmt.Sscanf(iter.Val(), "campaign:%d:meta", &id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan campaign metadata: %w", err)
+	}
+
+	meta, err := r.GetCampaignsMetadata(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	state.Metadata = meta
+
+	// 4. Target bitmaps (staging keys of imports end in a timestamp, not ":users")
+	state.Audiences = map[campaign.Audience]bool{}
+	iter = r.rdb.Scan(ctx, 0, "*:users", 500).Iterator()
+	for iter.Next(ctx) {
+		if a, ok := parseAudienceKey(iter.Val()); ok {
+			state.Audiences[a] = true
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan target bitmaps: %w", err)
+	}
+	return state, nil
+}
+
+// parseAudienceKey is the inverse of audienceKey.
+func parseAudienceKey(key string) (campaign.Audience, bool) {
+	rest, ok := strings.CutSuffix(key, ":users")
+	if !ok {
+		return campaign.Audience{}, false
+	}
+	if name, ok := strings.CutPrefix(rest, "segment:"); ok && name != "" {
+		return campaign.SegmentAudience(name), true
+	}
+	if idStr, ok := strings.CutPrefix(rest, "campaign:"); ok {
+		if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
+			return campaign.CampaignAudience(id), true
+		}
+	}
+	return campaign.Audience{}, false
+}